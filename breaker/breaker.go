@@ -1,10 +1,16 @@
 package breaker
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/mgiaccone/tripswitch/clock"
 	"github.com/mgiaccone/tripswitch/internal/coreutil"
 )
 
@@ -12,6 +18,7 @@ const (
 	_defaultFailThreshold    = int32(3)
 	_defaultSuccessThreshold = int32(3)
 	_defaultWaitInterval     = 30 * time.Second
+	_defaultWindowSize       = 10
 )
 
 var (
@@ -20,24 +27,71 @@ var (
 
 	// ErrPanicRecovered is a panic recovered error.
 	ErrPanicRecovered = coreutil.ErrPanicRecovered
+
+	// ErrBreakerClosed is returned when executing a function with a circuit breaker that has been closed.
+	ErrBreakerClosed = errors.New("circuit breaker closed")
+
+	// ErrTooManyRequests is returned when the maximum number of concurrent
+	// trial executions in the CircuitHalfOpen state has been reached.
+	ErrTooManyRequests = errors.New("too many requests")
 )
 
+// OpenCircuitError is the error returned when an execution is rejected because the circuit is open.
+// It matches ErrCircuitOpen when compared with errors.Is.
+type OpenCircuitError struct {
+	// Name is the name of the circuit breaker.
+	Name string
+
+	// State is the state of the circuit breaker, either CircuitOpen or CircuitForcedOpen.
+	State CircuitState
+
+	// OpenedAt is the time the circuit breaker entered its current state.
+	OpenedAt time.Time
+
+	// RetryAfter is the expected time until the circuit breaker enters the CircuitHalfOpen state.
+	// It is zero in the CircuitForcedOpen state, which is never left automatically.
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface.
+func (e *OpenCircuitError) Error() string {
+	if e.Name == "" {
+		return ErrCircuitOpen.Error()
+	}
+
+	return fmt.Sprintf("%s: %s", ErrCircuitOpen, e.Name)
+}
+
+// Is reports whether the target is ErrCircuitOpen.
+func (e *OpenCircuitError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
 // ProtectedFunc represents the function to be protected by the circuit breaker.
 type ProtectedFunc[T any] func() (T, error)
 
+// ProtectedContextFunc represents the context aware function to be protected by the circuit breaker.
+type ProtectedContextFunc[T any] func(ctx context.Context) (T, error)
+
 // Retrier is the interface representing a retrier.
 type Retrier[T any] interface {
 	Do(fn ProtectedFunc[T]) (T, error)
+	DoContext(ctx context.Context, fn ProtectedContextFunc[T]) (T, error)
 }
 
 // CircuitState represents the state of the circuit breaker.
 type CircuitState int32
 
 // Enumeration of circuit breaker states.
+// The CircuitForcedOpen, CircuitForcedClosed and CircuitDisabled states can only be set manually
+// and are never changed by the automatic transitions of the circuit breaker.
 const (
 	CircuitClosed CircuitState = iota << 1
 	CircuitHalfOpen
 	CircuitOpen
+	CircuitForcedOpen
+	CircuitForcedClosed
+	CircuitDisabled
 )
 
 // String implements the Stringer interface.
@@ -49,15 +103,34 @@ func (s CircuitState) String() string {
 		return "half-open"
 	case CircuitOpen:
 		return "open"
+	case CircuitForcedOpen:
+		return "forced-open"
+	case CircuitForcedClosed:
+		return "forced-closed"
+	case CircuitDisabled:
+		return "disabled"
 	}
 
 	return "undefined"
 }
 
+// classification represents how the outcome of an execution is accounted by the circuit breaker.
+type classification int
+
+// Enumeration of outcome classifications.
+const (
+	classifiedSuccess classification = iota
+	classifiedFailure
+	classifiedIgnored
+)
+
 // StateChangeFunc represents the function to handle state change notifications.
 type StateChangeFunc func(oldState, newState CircuitState)
 
-type restoreCircuitEvent struct{}
+// restoreCircuitEvent requests the recovery of the circuit opened by the state change of the given generation.
+type restoreCircuitEvent struct {
+	generation uint64
+}
 
 type stateChangeEvent struct {
 	oldState CircuitState
@@ -68,174 +141,856 @@ type notifyStateChangeFunc func(oldState, newState CircuitState)
 
 type notifyRecoverFunc func()
 
-// CircuitBreaker is the struct implementing the circuit breaker logic.
-type CircuitBreaker[T any] struct {
+// Circuit is the non-generic core of a circuit breaker, holding its state, counters and statistics.
+// It can be shared by multiple CircuitBreaker views with different result types, see For.
+type Circuit struct {
+	clock               clock.Clock
+	closeOnce           sync.Once
+	closed              int32
+	current             atomic.Value
+	done                chan struct{}
+	droppedStateChanges uint64
+	events              *eventHub
 	failCount           int32
-	failThreshold       int32
-	notifyStateChangeCh chan stateChangeEvent
+	generation          uint64
+	halfOpenCalls       int32
+	lazyRestore         bool
+	name                string
+	openedAt            int64
+	openUntil           int64
+	reconfigureLock     sync.Mutex
+	reopenCount         int32
 	state               CircuitState
 	successCount        int32
-	successThreshold    int32
+	stats               *statsCollector
 	restoreCircuitCh    chan restoreCircuitEvent
-	retrier             Retrier[T]
-	stateChangeFunc     StateChangeFunc
-	waitInterval        time.Duration
 
 	// these are used as test hooks
 	notifyStateChangeFn notifyStateChangeFunc
 	scheduleRecoverFn   notifyRecoverFunc
 }
 
-// NewCircuitBreaker creates a new instance of a circuit breaker.
-func NewCircuitBreaker[T any](opts ...Option) *CircuitBreaker[T] {
-	return NewCircuitBreakerWithRetrier[T](&nopRetrier[T]{}, opts...)
+// settings are the options of a circuit that can be replaced while it is running, see Reconfigure.
+type settings struct {
+	bucketWidth           time.Duration
+	failThreshold         int32
+	failurePredicate      any
+	failureRateThreshold  float64
+	fallback              any
+	halfOpenMaxCalls      int32
+	ignoreContextErrors   bool
+	ignoredErrors         []error
+	maxWaitInterval       time.Duration
+	minimumRequests       int
+	panicPolicy           PanicPolicy
+	slowCallRateThreshold float64
+	slowCallThreshold     time.Duration
+	stateChangeListeners  []*stateChangeListener
+	successThreshold      int32
+	waitInterval          time.Duration
+	waitJitter            float64
+	waitMultiplier        float64
+	window                window
+	windowSize            int
 }
 
-// NewCircuitBreakerWithRetrier creates a new instance of a circuit breaker .
-func NewCircuitBreakerWithRetrier[T any](retrier Retrier[T], opts ...Option) *CircuitBreaker[T] {
+func newSettings(cfg config) *settings {
+	s := settings{
+		bucketWidth:           cfg.bucketWidth,
+		failThreshold:         cfg.failThreshold,
+		failurePredicate:      cfg.failurePredicate,
+		failureRateThreshold:  cfg.failureRateThreshold,
+		fallback:              cfg.fallback,
+		halfOpenMaxCalls:      cfg.halfOpenMaxCalls,
+		ignoreContextErrors:   cfg.ignoreContextErrors,
+		ignoredErrors:         cfg.ignoredErrors,
+		maxWaitInterval:       cfg.maxWaitInterval,
+		minimumRequests:       minimumRequests(cfg),
+		panicPolicy:           cfg.panicPolicy,
+		slowCallRateThreshold: cfg.slowCallRateThreshold,
+		slowCallThreshold:     cfg.slowCallThreshold,
+		successThreshold:      cfg.successThreshold,
+		waitInterval:          cfg.waitInterval,
+		waitJitter:            cfg.waitJitter,
+		waitMultiplier:        cfg.waitMultiplier,
+		window:                newWindow(cfg),
+		windowSize:            cfg.windowSize,
+	}
+	fns := cfg.stateChangeFuncs
+	if cfg.stateChangeFunc != nil {
+		fns = append([]StateChangeFunc{cfg.stateChangeFunc}, fns...)
+	}
+	for _, fn := range fns {
+		s.stateChangeListeners = append(s.stateChangeListeners, newStateChangeListener(fn, cfg.stateChangeQueueSize))
+	}
+
+	return &s
+}
+
+// CircuitBreaker is a view of a Circuit wrapping the executions of the functions returning T.
+// All the methods of the underlying Circuit are available on the view.
+type CircuitBreaker[T any] struct {
+	*Circuit
+
+	retrier Retrier[T]
+}
+
+// NewCircuit creates a new instance of a circuit, to be used through one or more CircuitBreaker views.
+// The options are not validated, see NewValidatedCircuit.
+func NewCircuit(opts ...Option) *Circuit {
 	cfgOpts := _defaultOpts
 	cfgOpts = append(cfgOpts, opts...)
 
-	cfg := newConfig(cfgOpts...)
+	return newCircuit(newConfig(cfgOpts...))
+}
 
-	cb := CircuitBreaker[T]{
-		failThreshold:       cfg.failThreshold,
-		notifyStateChangeCh: make(chan stateChangeEvent),
-		restoreCircuitCh:    make(chan restoreCircuitEvent),
-		retrier:             retrier,
-		state:               CircuitClosed,
-		stateChangeFunc:     cfg.stateChangeFunc,
-		successThreshold:    cfg.successThreshold,
-		waitInterval:        cfg.waitInterval,
+// NewValidatedCircuit creates a new instance of a circuit, as NewCircuit does, once its options have been validated.
+// A ConfigError listing all the invalid settings is returned if the options are not valid.
+func NewValidatedCircuit(opts ...Option) (*Circuit, error) {
+	cfg, err := validatedConfig(opts)
+	if err != nil {
+		return nil, err
 	}
-	cb.scheduleRecoverFn = cb.scheduleRestore
+
+	return newCircuit(cfg), nil
+}
+
+func newCircuit(cfg config) *Circuit {
+	cb := Circuit{
+		clock:            cfg.clock,
+		done:             make(chan struct{}),
+		events:           newEventHub(),
+		lazyRestore:      cfg.lazyRestore,
+		name:             cfg.name,
+		restoreCircuitCh: make(chan restoreCircuitEvent),
+		state:            CircuitClosed,
+		stats:            newStatsCollector(cfg.clock.Now()),
+	}
+	cb.current.Store(newSettings(cfg))
 	cb.notifyStateChangeFn = cb.notifyStateChange
 
+	if cfg.lazyRestore {
+		cb.scheduleRecoverFn = cb.scheduleLazyRestore
+		return &cb
+	}
+
+	cb.scheduleRecoverFn = cb.scheduleRestore
+
 	go cb.processEvents()
 
 	return &cb
 }
 
+// NewCircuitBreaker creates a new instance of a circuit breaker.
+func NewCircuitBreaker[T any](opts ...Option) *CircuitBreaker[T] {
+	return NewCircuitBreakerWithRetrier[T](&nopRetrier[T]{}, opts...)
+}
+
+// NewCircuitBreakerWithRetrier creates a new instance of a circuit breaker .
+func NewCircuitBreakerWithRetrier[T any](retrier Retrier[T], opts ...Option) *CircuitBreaker[T] {
+	return newCircuitBreaker(NewCircuit(opts...), retrier)
+}
+
+// NewValidatedCircuitBreaker creates a new instance of a circuit breaker once its options have been validated.
+// A ConfigError listing all the invalid settings is returned if the options are not valid.
+func NewValidatedCircuitBreaker[T any](opts ...Option) (*CircuitBreaker[T], error) {
+	return NewValidatedCircuitBreakerWithRetrier[T](&nopRetrier[T]{}, opts...)
+}
+
+// NewValidatedCircuitBreakerWithRetrier creates a new instance of a circuit breaker with a retrier
+// once its options have been validated.
+// A ConfigError listing all the invalid settings is returned if the options are not valid.
+func NewValidatedCircuitBreakerWithRetrier[T any](retrier Retrier[T], opts ...Option) (*CircuitBreaker[T], error) {
+	if retrier == nil {
+		return nil, ErrRequiredRetrier
+	}
+
+	c, err := NewValidatedCircuit(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return newCircuitBreaker(c, retrier), nil
+}
+
+// For creates a view of the circuit wrapping the executions of the functions returning T.
+// All the views of a circuit share its state, counters and statistics, allowing to protect
+// the calls to the same dependency returning different result types.
+// The failure predicate and the fallback of the circuit are ignored by the views with a different result type.
+func For[T any](c *Circuit) *CircuitBreaker[T] {
+	return newCircuitBreaker[T](c, &nopRetrier[T]{})
+}
+
+func newCircuitBreaker[T any](c *Circuit, retrier Retrier[T]) *CircuitBreaker[T] {
+	return &CircuitBreaker[T]{
+		Circuit: c,
+		retrier: retrier,
+	}
+}
+
 // Do wraps a function execution with the circuit breaker.
-func (cb *CircuitBreaker[T]) Do(fn ProtectedFunc[T]) (res T, err error) {
-	err = ErrPanicRecovered
-	defer coreutil.RecoverPanic()
+// The fallback set with WithFallback, if any, is used when the execution fails or is rejected.
+func (cb *CircuitBreaker[T]) Do(fn ProtectedFunc[T]) (T, error) {
+	return cb.execute(context.Background(), wrapRetrier(cb.retrier, fn), cb.fallback())
+}
+
+// DoWithFallback wraps a function execution with the circuit breaker, returning the result of the
+// fallback function when the execution fails or is rejected. The fallback replaces the one set with
+// WithFallback, which is used instead when fallback is nil.
+// The rejections are passed to the fallback function wrapped in a RejectedError, allowing to
+// tell them apart from the failures of the protected function.
+func (cb *CircuitBreaker[T]) DoWithFallback(fn ProtectedFunc[T], fallback FallbackFunc[T]) (T, error) {
+	if fallback == nil {
+		fallback = cb.fallback()
+	}
+
+	return cb.execute(context.Background(), wrapRetrier(cb.retrier, fn), fallback)
+}
 
-	// fails immediately if the circuit state is CircuitOpen
-	if CircuitState(atomic.LoadInt32((*int32)(&cb.state))) == CircuitOpen {
-		return res, ErrCircuitOpen
+// DoContext wraps a context aware function execution with the circuit breaker.
+// The function is not executed and the context error is returned when the context is already done.
+// The fallback set with WithFallback, if any, is used when the execution fails or is rejected.
+func (cb *CircuitBreaker[T]) DoContext(ctx context.Context, fn ProtectedContextFunc[T]) (T, error) {
+	if err := ctx.Err(); err != nil {
+		// nolint:gocritic
+		return *new(T), err
 	}
 
-	res, err = wrapRetrier(cb.retrier, fn)()
+	return cb.execute(ctx, wrapRetrierContext(ctx, cb.retrier, fn), cb.fallback())
+}
+
+// Allow checks whether the circuit breaker allows an execution, for the code that cannot be wrapped
+// in a ProtectedFunc. If the execution is not allowed, the error explaining the reason is returned.
+// Otherwise, the returned function must be called with the error of the execution, or nil, once it
+// completes, to record its outcome. Only the first call of the returned function is recorded.
+func (cb *CircuitBreaker[T]) Allow() (func(err error), error) {
+	trial, err := cb.acquirePermission()
 	if err != nil {
-		cb.recordFailure()
-		return
+		return nil, err
+	}
+
+	var once sync.Once
+	startTime := cb.clock.Now()
+
+	done := func(err error) {
+		once.Do(func() {
+			elapsed := cb.clock.Now().Sub(startTime)
+			cb.releasePermission(trial)
+
+			// nolint:gocritic
+			cb.recordOutcome(context.Background(), *new(T), err, elapsed)
+		})
+	}
+
+	return done, nil
+}
+
+// execute runs the protected function and records the outcome of its execution.
+// When a fallback is given, its result is returned instead of the error of a failed or rejected execution.
+func (cb *CircuitBreaker[T]) execute(ctx context.Context, fn ProtectedFunc[T], fallback FallbackFunc[T]) (T, error) {
+	trial, err := cb.acquirePermission()
+	if err != nil {
+		if fallback != nil {
+			return fallback(&RejectedError{Err: err})
+		}
+
+		// nolint:gocritic
+		return *new(T), err
+	}
+
+	res, err := cb.call(ctx, trial, fn)
+	if err != nil && fallback != nil {
+		return fallback(err)
 	}
-	cb.recordSuccess()
+
+	return res, err
+}
+
+// call runs the protected function admitted by acquirePermission and records the outcome of its execution.
+// A panic of the protected function is recorded as a failure and handled according to the panic policy.
+func (cb *CircuitBreaker[T]) call(ctx context.Context, trial bool, fn ProtectedFunc[T]) (res T, err error) {
+	defer cb.releasePermission(trial)
+
+	startTime := cb.clock.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			err = cb.recordPanic(r, cb.clock.Now().Sub(startTime))
+		}
+	}()
+
+	res, err = fn()
+	elapsed := cb.clock.Now().Sub(startTime)
+
+	cb.recordOutcome(ctx, res, err, elapsed)
 
 	return
 }
 
+// recordPanic records a panic of the protected function as a failure and applies the panic policy.
+// It returns the error representing the recovered panic, unless the policy requires to panic again.
+func (cb *Circuit) recordPanic(r any, elapsed time.Duration) error {
+	err := coreutil.NewPanicError(r)
+
+	cb.stats.recordPanic()
+	cb.stats.recordOutcome(classifiedFailure, elapsed)
+	cb.publish(EventPanic, elapsed, err)
+	cb.recordFailure(elapsed)
+
+	switch cb.settings().panicPolicy {
+	case PanicReport:
+		reportPanic(cb.name, err)
+	case PanicRepanic:
+		panic(r)
+	}
+
+	return err
+}
+
+// recordOutcome classifies the outcome of a completed execution and records it.
+func (cb *CircuitBreaker[T]) recordOutcome(ctx context.Context, res T, err error, elapsed time.Duration) {
+	cb.record(cb.classify(ctx, res, err), elapsed, err)
+}
+
+// record records the classified outcome of a completed execution.
+func (cb *Circuit) record(class classification, elapsed time.Duration, err error) {
+	cb.stats.recordOutcome(class, elapsed)
+	cb.publishOutcome(class, elapsed, err)
+
+	switch class {
+	case classifiedIgnored:
+	case classifiedFailure:
+		cb.recordFailure(elapsed)
+	case classifiedSuccess:
+		cb.recordSuccess(elapsed)
+	}
+}
+
+// acquirePermission checks whether the current state of the circuit breaker allows an execution.
+// It fails immediately if the circuit breaker has been closed, if the circuit state is CircuitOpen
+// or CircuitForcedOpen or if the maximum number of concurrent trial executions has been reached
+// in the CircuitHalfOpen state.
+// It reports whether the execution has been admitted as a trial, to be released after its completion.
+func (cb *Circuit) acquirePermission() (bool, error) {
+	if atomic.LoadInt32(&cb.closed) == 1 {
+		return false, ErrBreakerClosed
+	}
+
+	if cb.lazyRestore {
+		cb.restoreExpiredCircuit()
+	}
+
+	state := CircuitState(atomic.LoadInt32((*int32)(&cb.state)))
+	cb.stats.recordRequest(state)
+
+	switch state {
+	case CircuitOpen, CircuitForcedOpen:
+		err := cb.openCircuitError(state)
+		cb.stats.recordRejection()
+		cb.publish(EventRejected, 0, err)
+		return false, err
+	case CircuitHalfOpen:
+		maxCalls := cb.settings().halfOpenMaxCalls
+		if maxCalls <= 0 {
+			return false, nil
+		}
+
+		if atomic.AddInt32(&cb.halfOpenCalls, 1) > maxCalls {
+			atomic.AddInt32(&cb.halfOpenCalls, -1)
+			cb.stats.recordRejection()
+			cb.publish(EventRejected, 0, ErrTooManyRequests)
+			return false, ErrTooManyRequests
+		}
+
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// openCircuitError creates the error returned when an execution is rejected in the given open state.
+func (cb *Circuit) openCircuitError(state CircuitState) error {
+	err := &OpenCircuitError{
+		Name:  cb.name,
+		State: state,
+	}
+
+	if openedAt := atomic.LoadInt64(&cb.openedAt); openedAt != 0 {
+		err.OpenedAt = time.Unix(0, openedAt)
+	}
+
+	if state == CircuitOpen {
+		if retryAfter := time.Unix(0, atomic.LoadInt64(&cb.openUntil)).Sub(cb.clock.Now()); retryAfter > 0 {
+			err.RetryAfter = retryAfter
+		}
+	}
+
+	return err
+}
+
+// releasePermission releases a trial execution admitted in the CircuitHalfOpen state.
+func (cb *Circuit) releasePermission(trial bool) {
+	if trial {
+		atomic.AddInt32(&cb.halfOpenCalls, -1)
+	}
+}
+
+// classify determines how the outcome of an execution is accounted by the circuit breaker.
+// The failure predicate of the circuit is only applied if it matches the result type.
+func (cb *CircuitBreaker[T]) classify(ctx context.Context, res T, err error) classification {
+	s := cb.settings()
+	if cb.ignores(s, ctx, err) {
+		return classifiedIgnored
+	}
+
+	isFailureFn, ok := s.failurePredicate.(func(res T, err error) bool)
+	if !ok {
+		isFailureFn = isFailure[T]
+	}
+
+	if isFailureFn(res, err) {
+		return classifiedFailure
+	}
+
+	return classifiedSuccess
+}
+
+// fallback returns the fallback function of the circuit, if it matches the result type.
+func (cb *CircuitBreaker[T]) fallback() FallbackFunc[T] {
+	fn, _ := cb.settings().fallback.(FallbackFunc[T])
+	return fn
+}
+
+// ignores reports whether the outcome of an execution must be ignored regardless of its result.
+// The outcome is always ignored when the circuit breaker is in its CircuitDisabled state.
+// nolint:revive
+func (cb *Circuit) ignores(s *settings, ctx context.Context, err error) bool {
+	if CircuitState(atomic.LoadInt32((*int32)(&cb.state))) == CircuitDisabled {
+		return true
+	}
+
+	if err != nil {
+		if s.ignoreContextErrors && isContextError(ctx, err) {
+			return true
+		}
+
+		for _, ignored := range s.ignoredErrors {
+			if errors.Is(err, ignored) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
 // State returns the current state of the circuit breaker.
-func (cb *CircuitBreaker[T]) State() CircuitState {
+// When the lazy restore is enabled, an expired CircuitOpen state is set to CircuitHalfOpen first.
+func (cb *Circuit) State() CircuitState {
+	if cb.lazyRestore {
+		cb.restoreExpiredCircuit()
+	}
+
 	return CircuitState(atomic.LoadInt32((*int32)(&cb.state)))
 }
 
-// processEvents handles all the internal events.
-func (cb *CircuitBreaker[T]) processEvents() {
+// ForceOpen sets the circuit breaker state to CircuitForcedOpen.
+// All the executions are rejected with ErrCircuitOpen until the state is changed manually.
+func (cb *Circuit) ForceOpen() {
+	cb.setState(CircuitForcedOpen)
+}
+
+// ForceClosed sets the circuit breaker state to CircuitForcedClosed.
+// All the executions are allowed and recorded, but the circuit never trips until the state is changed manually.
+func (cb *Circuit) ForceClosed() {
+	cb.setState(CircuitForcedClosed)
+}
+
+// Disable sets the circuit breaker state to CircuitDisabled.
+// All the executions are allowed and ignored by the circuit breaker until the state is changed manually.
+func (cb *Circuit) Disable() {
+	cb.setState(CircuitDisabled)
+}
+
+// Reset sets the circuit breaker state to CircuitClosed, clearing all its counters
+// and restoring its automatic transitions.
+func (cb *Circuit) Reset() {
+	atomic.StoreInt32(&cb.failCount, 0)
+	atomic.StoreInt32(&cb.reopenCount, 0)
+	atomic.StoreInt32(&cb.successCount, 0)
+	cb.resetWindow()
+
+	cb.setState(CircuitClosed)
+}
+
+// Subscribe registers a subscriber of the events of the circuit breaker.
+// Events are delivered asynchronously through the channel of the subscription, which must be
+// cancelled with Unsubscribe once no longer used.
+func (cb *Circuit) Subscribe(opts ...SubscribeOption) *Subscription {
+	return cb.events.subscribe(opts...)
+}
+
+// Stats returns a consistent snapshot of the runtime statistics of the circuit breaker.
+func (cb *Circuit) Stats() Stats {
+	if cb.lazyRestore {
+		cb.restoreExpiredCircuit()
+	}
+
+	stats := cb.stats.snapshot(cb.clock.Now())
+	stats.DroppedStateChanges = atomic.LoadUint64(&cb.droppedStateChanges)
+	for _, l := range cb.settings().stateChangeListeners {
+		stats.DroppedStateChanges += atomic.LoadUint64(&l.dropped)
+	}
+
+	return stats
+}
+
+// Reconfigure atomically replaces the options of the circuit breaker while it is running, preserving its state,
+// counters and statistics. The options are applied on top of the default options, as when the circuit breaker
+// is created. The clock, the name and the lazy restore mode of the circuit breaker cannot be changed.
+// The outcomes collected by the window of the failure rate and slow call rate are preserved, unless the window
+// size or the bucket width changes. A change of the wait interval applies from the next time the circuit opens.
+// The options are validated first, and a ConfigError is returned leaving the current options in place
+// if they are not valid.
+func (cb *Circuit) Reconfigure(opts ...Option) error {
+	cfgOpts := _defaultOpts
+	cfgOpts = append(cfgOpts, opts...)
+
+	cfg := newConfig(cfgOpts...)
+	cfg.clock = cb.clock
+	cfg.name = cb.name
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	cb.reconfigure(cfg)
+
+	return nil
+}
+
+// reconfigure replaces the settings of the circuit breaker with the ones of a validated configuration.
+// The clock and the name of the configuration are ignored.
+func (cb *Circuit) reconfigure(cfg config) {
+	cfg.clock = cb.clock
+	cfg.name = cb.name
+
+	cb.reconfigureLock.Lock()
+	defer cb.reconfigureLock.Unlock()
+
+	oldSettings := cb.settings()
+	newSettings := newSettings(cfg)
+	if oldSettings.window != nil && newSettings.window != nil &&
+		oldSettings.bucketWidth == newSettings.bucketWidth && oldSettings.windowSize == newSettings.windowSize {
+		newSettings.window = oldSettings.window
+	}
+
+	cb.current.Store(newSettings)
+
+	for _, l := range oldSettings.stateChangeListeners {
+		atomic.AddUint64(&cb.droppedStateChanges, atomic.LoadUint64(&l.dropped))
+	}
+}
+
+// settings returns the current options of the circuit breaker.
+func (cb *Circuit) settings() *settings {
+	return cb.current.Load().(*settings)
+}
+
+// setState unconditionally sets the circuit breaker state, publishing the state change if any.
+func (cb *Circuit) setState(newState CircuitState) {
+	oldState := CircuitState(atomic.SwapInt32((*int32)(&cb.state), int32(newState)))
+	if oldState != newState {
+		cb.onStateChange(oldState, newState)
+	}
+}
+
+// Close stops the background processing of the circuit breaker, cancels any pending recovery
+// and cancels all the subscriptions to its events.
+// All the subsequent executions fail immediately with ErrBreakerClosed.
+// Closing a circuit breaker more than once has no effect.
+func (cb *Circuit) Close() {
+	cb.closeOnce.Do(func() {
+		atomic.StoreInt32(&cb.closed, 1)
+		close(cb.done)
+		cb.events.close()
+	})
+}
+
+// onStateChange records a state change and publishes it.
+// Each state change starts a new generation, cancelling the recovery scheduled by the previous ones.
+func (cb *Circuit) onStateChange(oldState, newState CircuitState) {
+	atomic.AddUint64(&cb.generation, 1)
+
+	if newState == CircuitOpen || newState == CircuitForcedOpen {
+		atomic.StoreInt64(&cb.openedAt, cb.clock.Now().UnixNano())
+	}
+
+	cb.stats.recordTransition(oldState, newState, cb.clock.Now())
+	cb.notifyStateChangeFn(oldState, newState)
+
+	if cb.events.active() {
+		cb.events.publish(Event{
+			Circuit:  cb.name,
+			Kind:     EventStateChange,
+			Time:     cb.clock.Now(),
+			OldState: oldState,
+			NewState: newState,
+		})
+	}
+}
+
+// publish delivers an event to the subscribers, if any.
+func (cb *Circuit) publish(kind EventKind, elapsed time.Duration, err error) {
+	if !cb.events.active() {
+		return
+	}
+
+	cb.events.publish(Event{
+		Circuit:  cb.name,
+		Kind:     kind,
+		Time:     cb.clock.Now(),
+		Duration: elapsed,
+		Err:      err,
+	})
+}
+
+// publishOutcome delivers the events reporting the outcome of an execution to the subscribers, if any.
+func (cb *Circuit) publishOutcome(class classification, elapsed time.Duration, err error) {
+	switch class {
+	case classifiedSuccess:
+		cb.publish(EventSuccess, elapsed, err)
+	case classifiedFailure:
+		cb.publish(EventFailure, elapsed, err)
+	case classifiedIgnored:
+		cb.publish(EventIgnored, elapsed, err)
+	}
+
+	if cb.isSlowCall(elapsed) {
+		cb.publish(EventSlowCall, elapsed, err)
+	}
+}
+
+// processEvents handles all the internal events until the circuit breaker is closed.
+func (cb *Circuit) processEvents() {
 	for {
 		select {
-		case msg := <-cb.notifyStateChangeCh:
-			cb.stateChangeFunc(msg.oldState, msg.newState)
-		case <-cb.restoreCircuitCh:
-			go cb.restoreCircuit()
+		case e := <-cb.restoreCircuitCh:
+			go cb.restoreCircuit(e)
+		case <-cb.done:
+			return
 		}
 	}
 }
 
-// notifyStateChange publishes a state change.
-func (cb *CircuitBreaker[T]) notifyStateChange(oldState, newState CircuitState) {
-	cb.notifyStateChangeCh <- stateChangeEvent{
-		oldState: oldState,
-		newState: newState,
+// notifyStateChange publishes a state change to all the listeners without blocking.
+// The state change is discarded for the listeners whose queue is full.
+func (cb *Circuit) notifyStateChange(oldState, newState CircuitState) {
+	e := stateChangeEvent{oldState: oldState, newState: newState}
+	for _, l := range cb.settings().stateChangeListeners {
+		l.enqueue(e)
 	}
 }
 
 // scheduleRestore publishes a circuit recover request.
-func (cb *CircuitBreaker[T]) scheduleRestore() {
-	cb.restoreCircuitCh <- restoreCircuitEvent{}
+func (cb *Circuit) scheduleRestore() {
+	select {
+	case cb.restoreCircuitCh <- restoreCircuitEvent{generation: atomic.LoadUint64(&cb.generation)}:
+	case <-cb.done:
+	}
 }
 
 // recordFailure handles a failed function execution.
-// If the current state is CircuitClosed and the failure counter reached the threshold, it will set the circuit breaker state to CircuitOpen.
+// If the current state is CircuitClosed and the failure counter, the failure rate or the slow call rate
+// reached the threshold, it will set the circuit breaker state to CircuitOpen.
 // Otherwise, it resets the success counter and sets the state to CircuitOpen when the current state is CircuitHalfOpen.
-func (cb *CircuitBreaker[T]) recordFailure() {
+func (cb *Circuit) recordFailure(elapsed time.Duration) {
 	switch CircuitState(atomic.LoadInt32((*int32)(&cb.state))) {
-	case CircuitOpen:
-		// TODO: update stats
 	case CircuitClosed:
-		// TODO: update stats
-		if atomic.AddInt32(&cb.failCount, 1) < cb.failThreshold {
-			return
-		}
-
-		if atomic.CompareAndSwapInt32((*int32)(&cb.state), int32(CircuitClosed), int32(CircuitOpen)) {
-			cb.notifyStateChangeFn(CircuitClosed, CircuitOpen)
-			cb.scheduleRecoverFn()
+		failCount := atomic.AddInt32(&cb.failCount, 1)
+		if cb.shouldTrip(failCount, outcome{failure: true, slow: cb.isSlowCall(elapsed)}) {
+			cb.tripCircuit()
 		}
 	case CircuitHalfOpen:
-		// TODO: update stats
+		cb.setOpenUntil(atomic.LoadInt32(&cb.reopenCount) + 1)
 		if atomic.CompareAndSwapInt32((*int32)(&cb.state), int32(CircuitHalfOpen), int32(CircuitOpen)) {
 			atomic.AddInt32(&cb.failCount, 1)
+			atomic.AddInt32(&cb.reopenCount, 1)
 			atomic.StoreInt32(&cb.successCount, 0)
 
-			cb.notifyStateChangeFn(CircuitHalfOpen, CircuitOpen)
+			cb.onStateChange(CircuitHalfOpen, CircuitOpen)
 			cb.scheduleRecoverFn()
 		}
 	}
 }
 
 // recordSuccess handles a successful function execution.
+// If the current state is CircuitClosed and the slow call rate reached the threshold,
+// it will set the circuit breaker state to CircuitOpen.
 // If the current state is CircuitHalfOpen, it resets the circuit breaker.
-func (cb *CircuitBreaker[T]) recordSuccess() {
+func (cb *Circuit) recordSuccess(elapsed time.Duration) {
 	switch CircuitState(atomic.LoadInt32((*int32)(&cb.state))) {
-	case CircuitOpen:
-		// TODO: update stats
 	case CircuitClosed:
-		// TODO: update stats
 		if atomic.LoadInt32(&cb.failCount) > 0 {
 			atomic.StoreInt32(&cb.failCount, 0)
 		}
+
+		if cb.shouldTrip(0, outcome{slow: cb.isSlowCall(elapsed)}) {
+			cb.tripCircuit()
+		}
 	case CircuitHalfOpen:
-		// TODO: update stats
-		if atomic.AddInt32(&cb.successCount, 1) < cb.successThreshold {
+		if atomic.AddInt32(&cb.successCount, 1) < cb.settings().successThreshold {
 			return
 		}
 
 		if atomic.CompareAndSwapInt32((*int32)(&cb.state), int32(CircuitHalfOpen), int32(CircuitClosed)) {
 			atomic.StoreInt32(&cb.failCount, 0)
+			atomic.StoreInt32(&cb.reopenCount, 0)
 			atomic.StoreInt32(&cb.successCount, 0)
+			cb.resetWindow()
 
-			cb.notifyStateChangeFn(CircuitHalfOpen, CircuitClosed)
+			cb.onStateChange(CircuitHalfOpen, CircuitClosed)
 		}
 	}
 }
 
-// restoreCircuit waits for the configured interval before attempting to reopen the circuit.
+// tripCircuit sets the circuit breaker state from CircuitClosed to CircuitOpen.
+func (cb *Circuit) tripCircuit() {
+	if CircuitState(atomic.LoadInt32((*int32)(&cb.state))) != CircuitClosed {
+		return
+	}
+
+	cb.setOpenUntil(atomic.LoadInt32(&cb.reopenCount))
+	if atomic.CompareAndSwapInt32((*int32)(&cb.state), int32(CircuitClosed), int32(CircuitOpen)) {
+		cb.resetWindow()
+		cb.onStateChange(CircuitClosed, CircuitOpen)
+		cb.scheduleRecoverFn()
+	}
+}
+
+// shouldTrip records the outcome of an execution in a CircuitClosed state
+// and reports whether any of the configured thresholds has been reached.
+func (cb *Circuit) shouldTrip(failCount int32, o outcome) bool {
+	s := cb.settings()
+
+	var counts windowCounts
+	if s.window != nil {
+		counts = s.window.record(o)
+	}
+
+	if s.failureRateThreshold <= 0 && o.failure && failCount >= s.failThreshold {
+		return true
+	}
+
+	return s.exceedsFailureRate(counts) || s.exceedsSlowCallRate(counts)
+}
+
+// exceedsFailureRate reports whether the failure rate collected by the window reached the threshold.
+// The failure rate is only evaluated once the window collected the minimum number of requests.
+func (s *settings) exceedsFailureRate(counts windowCounts) bool {
+	return s.failureRateThreshold > 0 &&
+		counts.total >= s.minimumRequests &&
+		counts.failureRate() >= s.failureRateThreshold
+}
+
+// exceedsSlowCallRate reports whether the slow call rate collected by the window reached the threshold.
+// The slow call rate is only evaluated once the window collected the minimum number of requests.
+func (s *settings) exceedsSlowCallRate(counts windowCounts) bool {
+	return s.slowCallRateThreshold > 0 &&
+		counts.total >= s.minimumRequests &&
+		counts.slowCallRate() >= s.slowCallRateThreshold
+}
+
+// isSlowCall reports whether an execution took longer than the slow call threshold.
+func (cb *Circuit) isSlowCall(elapsed time.Duration) bool {
+	s := cb.settings()
+	return s.slowCallThreshold > 0 && elapsed >= s.slowCallThreshold
+}
+
+// resetWindow clears the outcomes collected by the window, if any.
+func (cb *Circuit) resetWindow() {
+	if w := cb.settings().window; w != nil {
+		w.reset()
+	}
+}
+
+// openInterval returns the time the circuit breaker will wait before entering the CircuitHalfOpen state.
+// The wait interval grows by the backoff multiplier each time the circuit reopens after a failed recovery,
+// up to the maximum wait interval, and it is randomized by the jitter factor.
+func (cb *Circuit) openInterval(reopenCount int32) time.Duration {
+	s := cb.settings()
+
+	interval := float64(s.waitInterval)
+	if s.waitMultiplier > 1 {
+		interval *= math.Pow(s.waitMultiplier, float64(reopenCount))
+	}
+
+	if s.maxWaitInterval > 0 && interval > float64(s.maxWaitInterval) {
+		interval = float64(s.maxWaitInterval)
+	}
+
+	if s.waitJitter > 0 {
+		// nolint:gosec
+		interval += interval * s.waitJitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(interval)
+}
+
+// restoreCircuit waits until the time recorded when the circuit opened before attempting to reopen the circuit.
 // If the current state is CircuitOpen, it sets a timer to setting the state to CircuitHalfOpen.
-func (cb *CircuitBreaker[T]) restoreCircuit() {
-	t := time.NewTimer(cb.waitInterval)
+// The recovery is cancelled if the circuit breaker is closed, or its state changes, in the meantime.
+func (cb *Circuit) restoreCircuit(e restoreCircuitEvent) {
+	t := cb.clock.NewTimer(time.Unix(0, atomic.LoadInt64(&cb.openUntil)).Sub(cb.clock.Now()))
 	defer t.Stop()
 
-	<-t.C
+	select {
+	case <-t.C():
+	case <-cb.done:
+		return
+	}
+
+	// a manual state change, such as Reset, cancels the pending recovery
+	if atomic.LoadUint64(&cb.generation) != e.generation {
+		return
+	}
+
+	cb.halfOpenCircuit()
+}
+
+// scheduleLazyRestore does not schedule any timer, as the time recorded when the circuit opened
+// is checked by restoreExpiredCircuit on the next use of the circuit.
+func (cb *Circuit) scheduleLazyRestore() {
+}
+
+// setOpenUntil records the time after which the circuit is allowed to enter the CircuitHalfOpen state,
+// once it has reopened after the given number of failed recoveries.
+// It must be called before the state changes to CircuitOpen, so that a concurrent restoreExpiredCircuit
+// never sees the circuit open with the time recorded by a previous opening.
+func (cb *Circuit) setOpenUntil(reopenCount int32) {
+	atomic.StoreInt64(&cb.openUntil, cb.clock.Now().Add(cb.openInterval(reopenCount)).UnixNano())
+}
+
+// restoreExpiredCircuit sets the circuit breaker state to CircuitHalfOpen
+// if the current state is CircuitOpen and the recorded open interval has expired.
+func (cb *Circuit) restoreExpiredCircuit() {
+	if CircuitState(atomic.LoadInt32((*int32)(&cb.state))) != CircuitOpen {
+		return
+	}
+
+	if cb.clock.Now().UnixNano() < atomic.LoadInt64(&cb.openUntil) {
+		return
+	}
 
+	cb.halfOpenCircuit()
+}
+
+// halfOpenCircuit sets the circuit breaker state from CircuitOpen to CircuitHalfOpen.
+func (cb *Circuit) halfOpenCircuit() {
 	if atomic.CompareAndSwapInt32((*int32)(&cb.state), int32(CircuitOpen), int32(CircuitHalfOpen)) {
 		atomic.StoreInt32(&cb.failCount, 0)
 		atomic.StoreInt32(&cb.successCount, 0)
 
-		cb.notifyStateChangeFn(CircuitOpen, CircuitHalfOpen)
+		cb.onStateChange(CircuitOpen, CircuitHalfOpen)
 	}
-	// TODO: update stats
 }
 
 // nopRetrier is a no operation implementation of a retrier.
@@ -248,9 +1003,35 @@ func (r *nopRetrier[T]) Do(fn ProtectedFunc[T]) (T, error) {
 	return fn()
 }
 
+// DoContext implement the ProtectedContextFunc interface.
+// nolint:revive
+func (r *nopRetrier[T]) DoContext(ctx context.Context, fn ProtectedContextFunc[T]) (T, error) {
+	return fn(ctx)
+}
+
 // wrapRetrier is a convenience function to apply a retrier to a circuit breaker.
 func wrapRetrier[T any](r Retrier[T], fn ProtectedFunc[T]) ProtectedFunc[T] {
 	return func() (T, error) {
 		return r.Do(fn)
 	}
 }
+
+// wrapRetrierContext is a convenience function to apply a retrier to a circuit breaker
+// for a context aware function.
+func wrapRetrierContext[T any](ctx context.Context, r Retrier[T], fn ProtectedContextFunc[T]) ProtectedFunc[T] {
+	return func() (T, error) {
+		return r.DoContext(ctx, fn)
+	}
+}
+
+// isFailure is the default failure predicate, classifying every error as a failure.
+func isFailure[T any](_ T, err error) bool {
+	return err != nil
+}
+
+// isContextError reports whether the error has been caused by a cancelled or expired context.
+func isContextError(ctx context.Context, err error) bool {
+	return ctx.Err() != nil ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
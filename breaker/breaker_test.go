@@ -1,13 +1,23 @@
 package breaker
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/mgiaccone/tripswitch/clock"
 )
 
 func TestNewCircuitBreaker(t *testing.T) {
@@ -27,12 +37,12 @@ func TestNewCircuitBreaker(t *testing.T) {
 				opts: nil,
 			},
 			wantFn: func(t *testing.T, cb *CircuitBreaker[any]) {
-				require.Equal(t, _defaultFailThreshold, cb.failThreshold,
-					"failThreshold: got = %v, want = %v", cb.failThreshold, _defaultFailThreshold)
-				require.Equal(t, _defaultSuccessThreshold, cb.successThreshold,
-					"successThreshold: got = %v, want = %v", cb.successThreshold, _defaultSuccessThreshold)
-				require.Equal(t, _defaultWaitInterval, cb.waitInterval,
-					"waitInterval: got = %v, want = %v", cb.waitInterval, _defaultWaitInterval)
+				require.Equal(t, _defaultFailThreshold, cb.settings().failThreshold,
+					"failThreshold: got = %v, want = %v", cb.settings().failThreshold, _defaultFailThreshold)
+				require.Equal(t, _defaultSuccessThreshold, cb.settings().successThreshold,
+					"successThreshold: got = %v, want = %v", cb.settings().successThreshold, _defaultSuccessThreshold)
+				require.Equal(t, _defaultWaitInterval, cb.settings().waitInterval,
+					"waitInterval: got = %v, want = %v", cb.settings().waitInterval, _defaultWaitInterval)
 			},
 		},
 		{
@@ -44,12 +54,12 @@ func TestNewCircuitBreaker(t *testing.T) {
 				},
 			},
 			wantFn: func(t *testing.T, cb *CircuitBreaker[any]) {
-				require.Equal(t, int32(10), cb.failThreshold,
-					"failThreshold: got = %v, want = %v", cb.failThreshold, 10)
-				require.Equal(t, int32(99), cb.successThreshold,
-					"successThreshold: got = %v, want = %v", cb.successThreshold, 99)
-				require.Equal(t, _defaultWaitInterval, cb.waitInterval,
-					"waitInterval: got = %v, want = %v", cb.waitInterval, _defaultWaitInterval)
+				require.Equal(t, int32(10), cb.settings().failThreshold,
+					"failThreshold: got = %v, want = %v", cb.settings().failThreshold, 10)
+				require.Equal(t, int32(99), cb.settings().successThreshold,
+					"successThreshold: got = %v, want = %v", cb.settings().successThreshold, 99)
+				require.Equal(t, _defaultWaitInterval, cb.settings().waitInterval,
+					"waitInterval: got = %v, want = %v", cb.settings().waitInterval, _defaultWaitInterval)
 			},
 		},
 	}
@@ -157,6 +167,823 @@ func TestCircuitBreaker_Do(t *testing.T) {
 	}
 }
 
+func TestFor(t *testing.T) {
+	testErr := fmt.Errorf("test error")
+
+	c := NewCircuit(
+		WithFailThreshold(2),
+		WithFailurePredicate(func(res int, err error) bool { return err != nil || res < 0 }),
+		WithWaitInterval(time.Hour),
+	)
+	defer c.Close()
+
+	intCB := For[int](c)
+	strCB := For[string](c)
+
+	_, err := intCB.Do(func() (int, error) { return -1, nil })
+	require.NoError(t, err, "Do() - err = %v, want no error", err)
+	require.Equal(t, int32(1), c.failCount, "Do() - failCount = %v, want = %v", c.failCount, 1)
+
+	_, err = strCB.Do(func() (string, error) { return "", testErr })
+	require.ErrorIs(t, err, testErr, "Do() - err = %v, wantErr = %v", err, testErr)
+	require.Equal(t, CircuitOpen, strCB.State(), "Do() - state = %v, want = %v", strCB.State(), CircuitOpen)
+	require.Equal(t, CircuitOpen, intCB.State(), "Do() - state = %v, want = %v", intCB.State(), CircuitOpen)
+
+	_, err = intCB.Do(func() (int, error) { return 1, nil })
+	require.ErrorIs(t, err, ErrCircuitOpen, "Do() - err = %v, wantErr = %v", err, ErrCircuitOpen)
+
+	got := c.Stats()
+	require.Equal(t, uint64(2), got.Failures, "Stats() - Failures = %v, want = %v", got.Failures, 2)
+	require.Equal(t, uint64(1), got.Rejections, "Stats() - Rejections = %v, want = %v", got.Rejections, 1)
+}
+
+func TestCircuitBreaker_Do_withClock(t *testing.T) {
+	testErr := fmt.Errorf("test error")
+	waitInterval := time.Minute
+
+	fakeClock := clock.NewFake(time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC))
+	stateCh := make(chan CircuitState, 1)
+
+	cb := NewCircuitBreaker[int](
+		WithClock(fakeClock),
+		WithFailThreshold(1),
+		WithSuccessThreshold(1),
+		WithWaitInterval(waitInterval),
+		WithStateChangeFunc(func(oldState, newState CircuitState) {
+			stateCh <- newState
+		}),
+	)
+	defer cb.Close()
+
+	_, err := cb.Do(func() (int, error) { return 0, testErr })
+	require.ErrorIs(t, err, testErr, "Do() - err = %v, wantErr = %v", err, testErr)
+	require.Equal(t, CircuitOpen, <-stateCh, "Do() - state = %v, want = %v", cb.State(), CircuitOpen)
+
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(waitInterval - time.Nanosecond)
+	require.Equal(t, CircuitOpen, cb.State(), "Advance() - state = %v, want = %v", cb.State(), CircuitOpen)
+
+	fakeClock.Advance(time.Nanosecond)
+	require.Equal(t, CircuitHalfOpen, <-stateCh, "Advance() - state = %v, want = %v", cb.State(), CircuitHalfOpen)
+
+	_, err = cb.Do(func() (int, error) { return 1, nil })
+	require.NoError(t, err, "Do() - err = %v, want no error", err)
+	require.Equal(t, CircuitClosed, <-stateCh, "Do() - state = %v, want = %v", cb.State(), CircuitClosed)
+}
+
+func TestCircuitBreaker_Reset_pendingRestore(t *testing.T) {
+	testErr := fmt.Errorf("test error")
+	waitInterval := 30 * time.Second
+
+	fakeClock := clock.NewFake(time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC))
+	stateCh := make(chan CircuitState, 4)
+
+	cb := NewCircuitBreaker[int](
+		WithClock(fakeClock),
+		WithFailThreshold(1),
+		WithWaitInterval(waitInterval),
+		WithStateChangeFunc(func(oldState, newState CircuitState) {
+			stateCh <- newState
+		}),
+	)
+	defer cb.Close()
+
+	_, _ = cb.Do(func() (int, error) { return 0, testErr })
+	require.Equal(t, CircuitOpen, <-stateCh, "Do() - state = %v, want = %v", cb.State(), CircuitOpen)
+	fakeClock.BlockUntil(1)
+
+	fakeClock.Advance(25 * time.Second)
+	cb.Reset()
+	require.Equal(t, CircuitClosed, <-stateCh, "Reset() - state = %v, want = %v", cb.State(), CircuitClosed)
+
+	_, _ = cb.Do(func() (int, error) { return 0, testErr })
+	require.Equal(t, CircuitOpen, <-stateCh, "Do() - state = %v, want = %v", cb.State(), CircuitOpen)
+	fakeClock.BlockUntil(2)
+
+	// the recovery scheduled before the reset must not reopen the circuit
+	fakeClock.Advance(5 * time.Second)
+	require.Never(t, func() bool { return cb.State() != CircuitOpen }, 100*time.Millisecond, 10*time.Millisecond,
+		"Advance() - state = %v, want = %v", cb.State(), CircuitOpen)
+
+	fakeClock.Advance(waitInterval - 5*time.Second)
+	require.Equal(t, CircuitHalfOpen, <-stateCh, "Advance() - state = %v, want = %v", cb.State(), CircuitHalfOpen)
+}
+
+func TestCircuitBreaker_Do_lazyRestore(t *testing.T) {
+	testErr := fmt.Errorf("test error")
+	waitInterval := time.Minute
+
+	fakeClock := clock.NewFake(time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC))
+
+	notifyCh := make(chan CircuitState, 3)
+
+	cb := NewCircuitBreaker[int](
+		WithClock(fakeClock),
+		WithFailThreshold(1),
+		WithSuccessThreshold(1),
+		WithLazyRestore(true),
+		WithWaitInterval(waitInterval),
+		WithStateChangeFunc(func(oldState, newState CircuitState) {
+			notifyCh <- newState
+		}),
+	)
+
+	_, err := cb.Do(func() (int, error) { return 0, testErr })
+	require.ErrorIs(t, err, testErr, "Do() - err = %v, wantErr = %v", err, testErr)
+	require.Equal(t, CircuitOpen, cb.State(), "Do() - state = %v, want = %v", cb.State(), CircuitOpen)
+	require.Equal(t, 0, fakeClock.Waiters(), "Do() - timers = %v, want = %v", fakeClock.Waiters(), 0)
+
+	fakeClock.Advance(waitInterval - time.Nanosecond)
+	_, err = cb.Do(func() (int, error) { return 1, nil })
+	require.ErrorIs(t, err, ErrCircuitOpen, "Do() - err = %v, wantErr = %v", err, ErrCircuitOpen)
+
+	fakeClock.Advance(time.Nanosecond)
+	require.Equal(t, CircuitHalfOpen, cb.State(), "State() - state = %v, want = %v", cb.State(), CircuitHalfOpen)
+
+	_, err = cb.Do(func() (int, error) { return 1, nil })
+	require.NoError(t, err, "Do() - err = %v, want no error", err)
+	require.Equal(t, CircuitClosed, cb.State(), "Do() - state = %v, want = %v", cb.State(), CircuitClosed)
+
+	want := []CircuitState{CircuitOpen, CircuitHalfOpen, CircuitClosed}
+	got := []CircuitState{<-notifyCh, <-notifyCh, <-notifyCh}
+	require.Equal(t, want, got, "notifyStateChange() - got = %v, want = %v", got, want)
+}
+
+func TestCircuitBreaker_Do_lazyRestoreConcurrent(t *testing.T) {
+	testErr := fmt.Errorf("test error")
+	callers := 8
+
+	for i := 0; i < 50; i++ {
+		cb := NewCircuitBreaker[int](WithFailThreshold(1), WithWaitInterval(time.Hour), WithLazyRestore(true))
+
+		var wg sync.WaitGroup
+		startCh := make(chan struct{})
+		for j := 0; j < callers; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-startCh
+				for k := 0; k < 10; k++ {
+					_, _ = cb.Do(func() (int, error) { return 0, testErr })
+					_ = cb.State()
+				}
+			}()
+		}
+		close(startCh)
+		wg.Wait()
+
+		// the circuit must not recover before the wait interval expires
+		stats := cb.Stats()
+		require.Equal(t, CircuitOpen, stats.State, "Do() - state = %v, want = %v", stats.State, CircuitOpen)
+		require.Zero(t, stats.States[CircuitHalfOpen].Transitions,
+			"Do() - half open transitions = %v, want = %v", stats.States[CircuitHalfOpen].Transitions, 0)
+	}
+}
+
+func TestCircuitBreaker_recordFailure_lazyRestore(t *testing.T) {
+	cb := NewCircuitBreaker[int](WithFailThreshold(1), WithWaitInterval(time.Hour), WithLazyRestore(true))
+
+	// an execution observing the state as soon as the circuit opens must find it open
+	var got []CircuitState
+	cb.notifyStateChangeFn = func(oldState, newState CircuitState) {
+		got = append(got, cb.State())
+	}
+
+	cb.recordFailure(0)
+
+	atomic.StoreInt32((*int32)(&cb.state), int32(CircuitHalfOpen))
+	atomic.StoreInt64(&cb.openUntil, 0)
+	cb.recordFailure(0)
+
+	want := []CircuitState{CircuitOpen, CircuitOpen}
+	require.Equal(t, want, got, "recordFailure() - got = %v, want = %v", got, want)
+}
+
+func TestNewCircuitBreaker_lazyRestore(t *testing.T) {
+	count := 100
+	before := runtime.NumGoroutine()
+
+	breakers := make([]*CircuitBreaker[int], 0, count)
+	for i := 0; i < count; i++ {
+		breakers = append(breakers, NewCircuitBreaker[int](WithLazyRestore(true)))
+	}
+
+	after := runtime.NumGoroutine()
+	require.Less(t, after-before, count, "NewCircuitBreaker() - goroutines = %v, want < %v", after-before, count)
+	require.Len(t, breakers, count)
+}
+
+func TestCircuitBreaker_DoContext(t *testing.T) {
+	type ctxKey struct{}
+
+	testErr := fmt.Errorf("test error")
+
+	cancelledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name          string
+		ctx           context.Context
+		opts          []Option
+		err           error
+		wantCalled    bool
+		wantErr       error
+		wantFailCount int32
+	}{
+		{
+			name:          "success propagates the context",
+			ctx:           context.WithValue(context.Background(), ctxKey{}, "value"),
+			wantCalled:    true,
+			wantErr:       nil,
+			wantFailCount: 0,
+		},
+		{
+			name:          "cancelled context is rejected up front",
+			ctx:           cancelledCtx,
+			wantCalled:    false,
+			wantErr:       context.Canceled,
+			wantFailCount: 0,
+		},
+		{
+			name:          "failure is recorded",
+			ctx:           context.Background(),
+			err:           testErr,
+			wantCalled:    true,
+			wantErr:       testErr,
+			wantFailCount: 1,
+		},
+		{
+			name:          "context error is recorded by default",
+			ctx:           context.Background(),
+			err:           context.DeadlineExceeded,
+			wantCalled:    true,
+			wantErr:       context.DeadlineExceeded,
+			wantFailCount: 1,
+		},
+		{
+			name:          "context error is ignored when configured",
+			ctx:           context.Background(),
+			opts:          []Option{WithIgnoreContextErrors(true)},
+			err:           fmt.Errorf("wrapped: %w", context.DeadlineExceeded),
+			wantCalled:    true,
+			wantErr:       context.DeadlineExceeded,
+			wantFailCount: 0,
+		},
+		{
+			name:          "other errors are recorded when context errors are ignored",
+			ctx:           context.Background(),
+			opts:          []Option{WithIgnoreContextErrors(true)},
+			err:           testErr,
+			wantCalled:    true,
+			wantErr:       testErr,
+			wantFailCount: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			cb := NewCircuitBreaker[int](tt.opts...)
+
+			called := false
+			got, err := cb.DoContext(tt.ctx, func(ctx context.Context) (int, error) {
+				called = true
+				require.Equal(t, tt.ctx.Value(ctxKey{}), ctx.Value(ctxKey{}),
+					"DoContext() - context value = %v, want = %v", ctx.Value(ctxKey{}), tt.ctx.Value(ctxKey{}))
+				return 1, tt.err
+			})
+
+			want := 0
+			if called {
+				want = 1
+			}
+
+			require.Equal(t, tt.wantCalled, called, "DoContext() - called = %v, want = %v", called, tt.wantCalled)
+			require.Equal(t, want, got, "DoContext() - got = %v, want = %v", got, want)
+			require.ErrorIs(t, err, tt.wantErr, "DoContext() - err = %v, wantErr = %v", err, tt.wantErr)
+			require.Equal(t, tt.wantFailCount, cb.failCount,
+				"DoContext() - failCount = %v, want = %v", cb.failCount, tt.wantFailCount)
+		})
+	}
+}
+
+func TestCircuitBreaker_Do_panicPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      PanicPolicy
+		wantRepanic bool
+		wantReport  bool
+	}{
+		{
+			name:   "recover",
+			policy: PanicRecover,
+		},
+		{
+			name:       "report",
+			policy:     PanicReport,
+			wantReport: true,
+		},
+		{
+			name:        "repanic",
+			policy:      PanicRepanic,
+			wantRepanic: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logBuf bytes.Buffer
+			log.SetOutput(&logBuf)
+			t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+			cb := NewCircuitBreaker[int](WithFailThreshold(1), WithName("sample"), WithPanicPolicy(tt.policy))
+			defer cb.Close()
+
+			fn := func() (int, error) { panic("test panic") }
+
+			if tt.wantRepanic {
+				require.PanicsWithValue(t, "test panic", func() { _, _ = cb.Do(fn) },
+					"Do() - want panic with value = %v", "test panic")
+			} else {
+				_, err := cb.Do(fn)
+				require.ErrorIs(t, err, ErrPanicRecovered, "Do() - err = %v, wantErr = %v", err, ErrPanicRecovered)
+
+				var panicErr *PanicError
+				require.ErrorAs(t, err, &panicErr, "Do() - err = %v, want PanicError", err)
+				require.Equal(t, "test panic", panicErr.Value, "Do() - Value = %v, want = %v", panicErr.Value, "test panic")
+				require.NotEmpty(t, panicErr.Stack, "Do() - Stack is empty")
+			}
+
+			gotReport := strings.Contains(logBuf.String(), "test panic")
+			require.Equal(t, tt.wantReport, gotReport, "Do() - report = %v, want = %v", gotReport, tt.wantReport)
+
+			stats := cb.Stats()
+			require.Equal(t, uint64(1), stats.Panics, "Stats() - Panics = %v, want = %v", stats.Panics, 1)
+			require.Equal(t, uint64(1), stats.Failures, "Stats() - Failures = %v, want = %v", stats.Failures, 1)
+			require.Equal(t, CircuitOpen, cb.State(), "Do() - state = %v, want = %v", cb.State(), CircuitOpen)
+		})
+	}
+}
+
+func TestCircuitBreaker_Do_panicHalfOpen(t *testing.T) {
+	cb := NewCircuitBreaker[int](WithHalfOpenMaxCalls(1), WithPanicPolicy(PanicRepanic))
+	defer cb.Close()
+	cb.state = CircuitHalfOpen
+
+	require.Panics(t, func() { _, _ = cb.Do(func() (int, error) { panic("test panic") }) },
+		"Do() - want panic")
+	require.Equal(t, CircuitOpen, cb.State(), "Do() - state = %v, want = %v", cb.State(), CircuitOpen)
+	require.Equal(t, int32(0), cb.halfOpenCalls, "Do() - halfOpenCalls = %v, want = %v", cb.halfOpenCalls, 0)
+}
+
+func TestCircuitBreaker_DoWithFallback(t *testing.T) {
+	testErr := fmt.Errorf("test error")
+	fallbackErr := fmt.Errorf("fallback error")
+
+	fallback := func(err error) (int, error) {
+		var rejected *RejectedError
+		if errors.As(err, &rejected) {
+			return -1, nil
+		}
+		return -2, err
+	}
+
+	tests := []struct {
+		name        string
+		opts        []Option
+		state       CircuitState
+		fn          ProtectedFunc[int]
+		fallback    FallbackFunc[int]
+		wantRes     int
+		wantErr     error
+		wantNoError bool
+	}{
+		{
+			name:        "success does not use fallback",
+			fn:          func() (int, error) { return 1, nil },
+			fallback:    fallback,
+			wantRes:     1,
+			wantNoError: true,
+		},
+		{
+			name:     "failure is passed to fallback",
+			fn:       func() (int, error) { return 0, testErr },
+			fallback: fallback,
+			wantRes:  -2,
+			wantErr:  testErr,
+		},
+		{
+			name:        "rejection is passed to fallback",
+			state:       CircuitOpen,
+			fn:          func() (int, error) { return 1, nil },
+			fallback:    fallback,
+			wantRes:     -1,
+			wantNoError: true,
+		},
+		{
+			name:    "configured fallback",
+			opts:    []Option{WithFallback[int](func(err error) (int, error) { return -3, fallbackErr })},
+			fn:      func() (int, error) { return 0, testErr },
+			wantRes: -3,
+			wantErr: fallbackErr,
+		},
+		{
+			name:     "fallback overrides configured fallback",
+			opts:     []Option{WithFallback[int](func(err error) (int, error) { return -3, fallbackErr })},
+			fn:       func() (int, error) { return 0, testErr },
+			fallback: fallback,
+			wantRes:  -2,
+			wantErr:  testErr,
+		},
+		{
+			name:    "no fallback",
+			state:   CircuitOpen,
+			fn:      func() (int, error) { return 1, nil },
+			wantErr: ErrCircuitOpen,
+		},
+		{
+			name:     "panic is passed to fallback",
+			fn:       func() (int, error) { panic("test panic") },
+			fallback: fallback,
+			wantRes:  -2,
+			wantErr:  ErrPanicRecovered,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb := NewCircuitBreaker[int](tt.opts...)
+			defer cb.Close()
+			cb.state = tt.state
+
+			gotRes, gotErr := cb.DoWithFallback(tt.fn, tt.fallback)
+			if tt.wantNoError {
+				require.NoError(t, gotErr, "DoWithFallback() - err = %v, want no error", gotErr)
+			} else {
+				require.ErrorIs(t, gotErr, tt.wantErr, "DoWithFallback() - err = %v, wantErr = %v", gotErr, tt.wantErr)
+			}
+			require.Equal(t, tt.wantRes, gotRes, "DoWithFallback() - res = %v, want = %v", gotRes, tt.wantRes)
+		})
+	}
+}
+
+func TestCircuitBreaker_Do_withFallback(t *testing.T) {
+	var gotFallbackErr error
+	cb := NewCircuitBreaker[int](WithFailThreshold(1), WithWaitInterval(time.Hour),
+		WithFallback(func(err error) (int, error) {
+			gotFallbackErr = err
+			return -1, nil
+		}),
+	)
+	defer cb.Close()
+
+	res, err := cb.Do(func() (int, error) { return 0, fmt.Errorf("test error") })
+	require.NoError(t, err, "Do() - err = %v, want no error", err)
+	require.Equal(t, -1, res, "Do() - res = %v, want = %v", res, -1)
+	require.Equal(t, CircuitOpen, cb.State(), "Do() - state = %v, want = %v", cb.State(), CircuitOpen)
+
+	res, err = cb.Do(func() (int, error) { return 1, nil })
+	require.NoError(t, err, "Do() - err = %v, want no error", err)
+	require.Equal(t, -1, res, "Do() - res = %v, want = %v", res, -1)
+
+	var rejected *RejectedError
+	require.ErrorAs(t, gotFallbackErr, &rejected, "Do() - fallback err = %v, want RejectedError", gotFallbackErr)
+	require.ErrorIs(t, gotFallbackErr, ErrCircuitOpen, "Do() - fallback err = %v, wantErr = %v", gotFallbackErr, ErrCircuitOpen)
+}
+
+func TestCircuitBreaker_Allow(t *testing.T) {
+	testErr := fmt.Errorf("test error")
+
+	cb := NewCircuitBreaker[int](WithFailThreshold(2), WithWaitInterval(time.Hour))
+	defer cb.Close()
+
+	done, err := cb.Allow()
+	require.NoError(t, err, "Allow() - err = %v, want no error", err)
+	done(nil)
+
+	done, err = cb.Allow()
+	require.NoError(t, err, "Allow() - err = %v, want no error", err)
+	done(testErr)
+	done(testErr)
+	require.Equal(t, CircuitClosed, cb.State(), "Allow() - state = %v, want = %v", cb.State(), CircuitClosed)
+	require.Equal(t, int32(1), cb.failCount, "Allow() - failCount = %v, want = %v", cb.failCount, 1)
+
+	done, err = cb.Allow()
+	require.NoError(t, err, "Allow() - err = %v, want no error", err)
+	done(testErr)
+	require.Equal(t, CircuitOpen, cb.State(), "Allow() - state = %v, want = %v", cb.State(), CircuitOpen)
+
+	done, err = cb.Allow()
+	require.ErrorIs(t, err, ErrCircuitOpen, "Allow() - err = %v, wantErr = %v", err, ErrCircuitOpen)
+	require.Nil(t, done, "Allow() - done = %v, want nil", done)
+
+	stats := cb.Stats()
+	require.Equal(t, uint64(1), stats.Successes, "Stats() - Successes = %v, want = %v", stats.Successes, 1)
+	require.Equal(t, uint64(2), stats.Failures, "Stats() - Failures = %v, want = %v", stats.Failures, 2)
+	require.Equal(t, uint64(1), stats.Rejections, "Stats() - Rejections = %v, want = %v", stats.Rejections, 1)
+}
+
+func TestCircuitBreaker_Allow_halfOpenMaxCalls(t *testing.T) {
+	cb := NewCircuitBreaker[int](WithHalfOpenMaxCalls(1), WithSuccessThreshold(2))
+	defer cb.Close()
+	cb.state = CircuitHalfOpen
+
+	done, err := cb.Allow()
+	require.NoError(t, err, "Allow() - err = %v, want no error", err)
+
+	_, err = cb.Allow()
+	require.ErrorIs(t, err, ErrTooManyRequests, "Allow() - err = %v, wantErr = %v", err, ErrTooManyRequests)
+
+	done(nil)
+
+	done, err = cb.Allow()
+	require.NoError(t, err, "Allow() - err = %v, want no error", err)
+	done(nil)
+	require.Equal(t, CircuitClosed, cb.State(), "Allow() - state = %v, want = %v", cb.State(), CircuitClosed)
+}
+
+func TestCircuitBreaker_Do_openCircuitError(t *testing.T) {
+	startTime := time.Unix(1000, 0)
+
+	tests := []struct {
+		name           string
+		lazyRestore    bool
+		forceOpen      bool
+		advance        time.Duration
+		wantState      CircuitState
+		wantRetryAfter time.Duration
+	}{
+		{
+			name:           "open circuit",
+			advance:        20 * time.Second,
+			wantState:      CircuitOpen,
+			wantRetryAfter: 10 * time.Second,
+		},
+		{
+			name:           "open circuit with lazy restore",
+			lazyRestore:    true,
+			advance:        20 * time.Second,
+			wantState:      CircuitOpen,
+			wantRetryAfter: 10 * time.Second,
+		},
+		{
+			name:           "forced open circuit",
+			forceOpen:      true,
+			advance:        20 * time.Second,
+			wantState:      CircuitForcedOpen,
+			wantRetryAfter: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeClock := clock.NewFake(startTime)
+			cb := NewCircuitBreaker[int](
+				WithClock(fakeClock),
+				WithFailThreshold(1),
+				WithLazyRestore(tt.lazyRestore),
+				WithName("sample"),
+				WithWaitInterval(30*time.Second),
+			)
+			defer cb.Close()
+
+			if tt.forceOpen {
+				cb.ForceOpen()
+			} else {
+				_, _ = cb.Do(func() (int, error) { return 0, fmt.Errorf("test error") })
+			}
+			fakeClock.Advance(tt.advance)
+
+			_, err := cb.Do(func() (int, error) { return 1, nil })
+			require.ErrorIs(t, err, ErrCircuitOpen, "Do() - err = %v, wantErr = %v", err, ErrCircuitOpen)
+
+			var openErr *OpenCircuitError
+			require.ErrorAs(t, err, &openErr, "Do() - err = %v, want OpenCircuitError", err)
+			require.Equal(t, "sample", openErr.Name, "Do() - Name = %v, want = %v", openErr.Name, "sample")
+			require.Equal(t, tt.wantState, openErr.State, "Do() - State = %v, want = %v", openErr.State, tt.wantState)
+			require.Equal(t, startTime, openErr.OpenedAt, "Do() - OpenedAt = %v, want = %v", openErr.OpenedAt, startTime)
+			require.Equal(t, tt.wantRetryAfter, openErr.RetryAfter,
+				"Do() - RetryAfter = %v, want = %v", openErr.RetryAfter, tt.wantRetryAfter)
+			require.Equal(t, "circuit open: sample", err.Error(), "Do() - Error() = %v, want = %v", err.Error(), "circuit open: sample")
+		})
+	}
+}
+
+func TestCircuitBreaker_acquirePermission(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          []Option
+		state         CircuitState
+		halfOpenCalls int32
+		wantTrial     bool
+		wantErr       error
+	}{
+		{
+			name:  "closed circuit",
+			state: CircuitClosed,
+		},
+		{
+			name:    "open circuit",
+			state:   CircuitOpen,
+			wantErr: ErrCircuitOpen,
+		},
+		{
+			name:          "half open circuit without limit",
+			state:         CircuitHalfOpen,
+			halfOpenCalls: 100,
+		},
+		{
+			name:          "half open circuit below limit",
+			opts:          []Option{WithHalfOpenMaxCalls(2)},
+			state:         CircuitHalfOpen,
+			halfOpenCalls: 1,
+			wantTrial:     true,
+		},
+		{
+			name:          "half open circuit at limit",
+			opts:          []Option{WithHalfOpenMaxCalls(2)},
+			state:         CircuitHalfOpen,
+			halfOpenCalls: 2,
+			wantErr:       ErrTooManyRequests,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			cb := NewCircuitBreaker[any](tt.opts...)
+			cb.state = tt.state
+			cb.halfOpenCalls = tt.halfOpenCalls
+
+			trial, err := cb.acquirePermission()
+			require.Equal(t, tt.wantTrial, trial, "acquirePermission() - trial = %v, want = %v", trial, tt.wantTrial)
+			require.ErrorIs(t, err, tt.wantErr, "acquirePermission() - err = %v, wantErr = %v", err, tt.wantErr)
+
+			cb.releasePermission(trial)
+			require.Equal(t, tt.halfOpenCalls, cb.halfOpenCalls,
+				"releasePermission() - halfOpenCalls = %v, want = %v", cb.halfOpenCalls, tt.halfOpenCalls)
+		})
+	}
+}
+
+func TestCircuitBreaker_halfOpenMaxCalls(t *testing.T) {
+	cb := NewCircuitBreaker[int](WithHalfOpenMaxCalls(1), WithSuccessThreshold(2))
+	cb.state = CircuitHalfOpen
+
+	startedCh := make(chan struct{})
+	releaseCh := make(chan struct{})
+	doneCh := make(chan error)
+
+	go func() {
+		_, err := cb.Do(func() (int, error) {
+			close(startedCh)
+			<-releaseCh
+			return 1, nil
+		})
+		doneCh <- err
+	}()
+	<-startedCh
+
+	_, err := cb.Do(func() (int, error) { return 2, nil })
+	require.ErrorIs(t, err, ErrTooManyRequests, "Do() - err = %v, wantErr = %v", err, ErrTooManyRequests)
+
+	close(releaseCh)
+	err = <-doneCh
+	require.NoError(t, err, "Do() - err = %v, want no error", err)
+
+	_, err = cb.Do(func() (int, error) { return 3, nil })
+	require.NoError(t, err, "Do() - err = %v, want no error", err)
+	require.Equal(t, CircuitClosed, cb.State(), "Do() - state = %v, want = %v", cb.State(), CircuitClosed)
+}
+
+func TestCircuitBreaker_classify(t *testing.T) {
+	testErr := fmt.Errorf("test error")
+	validationErr := fmt.Errorf("validation error")
+
+	unavailable := func(res int, err error) bool {
+		return err != nil || res == 503
+	}
+
+	tests := []struct {
+		name string
+		opts []Option
+		ctx  context.Context
+		res  int
+		err  error
+		want classification
+	}{
+		{
+			name: "success by default",
+			res:  200,
+			want: classifiedSuccess,
+		},
+		{
+			name: "failure by default",
+			err:  testErr,
+			want: classifiedFailure,
+		},
+		{
+			name: "ignored error",
+			opts: []Option{WithIgnoredErrors(validationErr)},
+			err:  fmt.Errorf("wrapped: %w", validationErr),
+			want: classifiedIgnored,
+		},
+		{
+			name: "not ignored error",
+			opts: []Option{WithIgnoredErrors(validationErr)},
+			err:  testErr,
+			want: classifiedFailure,
+		},
+		{
+			name: "ignored context error",
+			opts: []Option{WithIgnoreContextErrors(true)},
+			err:  context.Canceled,
+			want: classifiedIgnored,
+		},
+		{
+			name: "result classified as failure by predicate",
+			opts: []Option{WithFailurePredicate(unavailable)},
+			res:  503,
+			want: classifiedFailure,
+		},
+		{
+			name: "result classified as success by predicate",
+			opts: []Option{WithFailurePredicate(unavailable)},
+			res:  200,
+			want: classifiedSuccess,
+		},
+		{
+			name: "error classified as success by predicate",
+			opts: []Option{WithFailurePredicate(func(_ int, err error) bool { return !errors.Is(err, validationErr) })},
+			err:  validationErr,
+			want: classifiedSuccess,
+		},
+		{
+			name: "predicate for a different result type is ignored",
+			opts: []Option{WithFailurePredicate(func(_ string, _ error) bool { return true })},
+			res:  200,
+			want: classifiedSuccess,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			cb := NewCircuitBreaker[int](tt.opts...)
+
+			got := cb.classify(ctx, tt.res, tt.err)
+			require.Equal(t, tt.want, got, "classify() - got = %v, want = %v", got, tt.want)
+		})
+	}
+}
+
+func TestCircuitBreaker_Stats(t *testing.T) {
+	testErr := fmt.Errorf("test error")
+	startTime := time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	fakeClock := clock.NewFake(startTime)
+	cb := NewCircuitBreaker[int](
+		WithClock(fakeClock),
+		WithFailThreshold(2),
+		WithIgnoredErrors(context.Canceled),
+		WithLazyRestore(true),
+		WithWaitInterval(time.Minute),
+	)
+
+	_, _ = cb.Do(func() (int, error) {
+		fakeClock.Advance(10 * time.Millisecond)
+		return 1, nil
+	})
+	_, _ = cb.Do(func() (int, error) { return 0, context.Canceled })
+	_, _ = cb.Do(func() (int, error) { panic("test panic") })
+	_, _ = cb.Do(func() (int, error) {
+		fakeClock.Advance(20 * time.Millisecond)
+		return 0, testErr
+	})
+	_, _ = cb.Do(func() (int, error) { return 1, nil })
+
+	fakeClock.Advance(time.Second)
+
+	got := cb.Stats()
+	require.Equal(t, CircuitOpen, got.State, "Stats() - State = %v, want = %v", got.State, CircuitOpen)
+	require.Equal(t, uint64(5), got.Requests, "Stats() - Requests = %v, want = %v", got.Requests, 5)
+	require.Equal(t, uint64(1), got.Successes, "Stats() - Successes = %v, want = %v", got.Successes, 1)
+	require.Equal(t, uint64(2), got.Failures, "Stats() - Failures = %v, want = %v", got.Failures, 2)
+	require.Equal(t, uint64(1), got.Ignored, "Stats() - Ignored = %v, want = %v", got.Ignored, 1)
+	require.Equal(t, uint64(1), got.Rejections, "Stats() - Rejections = %v, want = %v", got.Rejections, 1)
+	require.Equal(t, uint64(1), got.Panics, "Stats() - Panics = %v, want = %v", got.Panics, 1)
+	require.Equal(t, uint64(2), got.ConsecutiveFailures,
+		"Stats() - ConsecutiveFailures = %v, want = %v", got.ConsecutiveFailures, 2)
+	require.Equal(t, startTime.Add(30*time.Millisecond), got.LastTransition,
+		"Stats() - LastTransition = %v, want = %v", got.LastTransition, startTime.Add(30*time.Millisecond))
+	require.Equal(t, time.Second, got.States[CircuitOpen].Duration,
+		"Stats() - open duration = %v, want = %v", got.States[CircuitOpen].Duration, time.Second)
+	require.Equal(t, 20*time.Millisecond, got.Latency.Max,
+		"Stats() - Latency.Max = %v, want = %v", got.Latency.Max, 20*time.Millisecond)
+}
+
 func TestCircuitBreaker_State(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -178,12 +1005,27 @@ func TestCircuitBreaker_State(t *testing.T) {
 			state: CircuitOpen,
 			want:  CircuitOpen,
 		},
+		{
+			name:  "return forced open state for existing circuit",
+			state: CircuitForcedOpen,
+			want:  CircuitForcedOpen,
+		},
+		{
+			name:  "return forced closed state for existing circuit",
+			state: CircuitForcedClosed,
+			want:  CircuitForcedClosed,
+		},
+		{
+			name:  "return disabled state for existing circuit",
+			state: CircuitDisabled,
+			want:  CircuitDisabled,
+		},
 	}
 
 	for _, tt := range tests {
 		tt := tt
 		t.Run(tt.name, func(t *testing.T) {
-			cb := &CircuitBreaker[any]{
+			cb := &Circuit{
 				state: tt.state,
 			}
 
@@ -193,6 +1035,153 @@ func TestCircuitBreaker_State(t *testing.T) {
 	}
 }
 
+func TestCircuitBreaker_manualOverride(t *testing.T) {
+	testErr := fmt.Errorf("test error")
+
+	tests := []struct {
+		name          string
+		state         CircuitState
+		overrideFn    func(cb *CircuitBreaker[int])
+		err           error
+		wantState     CircuitState
+		wantErr       error
+		wantFailCount int32
+		wantNotify    []CircuitState
+	}{
+		{
+			name:          "force open rejects executions",
+			state:         CircuitClosed,
+			overrideFn:    (*CircuitBreaker[int]).ForceOpen,
+			wantState:     CircuitForcedOpen,
+			wantErr:       ErrCircuitOpen,
+			wantFailCount: 0,
+			wantNotify:    []CircuitState{CircuitClosed, CircuitForcedOpen},
+		},
+		{
+			name:          "force closed records failures without tripping",
+			state:         CircuitOpen,
+			overrideFn:    (*CircuitBreaker[int]).ForceClosed,
+			err:           testErr,
+			wantState:     CircuitForcedClosed,
+			wantErr:       testErr,
+			wantFailCount: 0,
+			wantNotify:    []CircuitState{CircuitOpen, CircuitForcedClosed},
+		},
+		{
+			name:          "disable ignores failures",
+			state:         CircuitHalfOpen,
+			overrideFn:    (*CircuitBreaker[int]).Disable,
+			err:           testErr,
+			wantState:     CircuitDisabled,
+			wantErr:       testErr,
+			wantFailCount: 0,
+			wantNotify:    []CircuitState{CircuitHalfOpen, CircuitDisabled},
+		},
+		{
+			name:          "reset restores automatic transitions",
+			state:         CircuitForcedOpen,
+			overrideFn:    (*CircuitBreaker[int]).Reset,
+			err:           testErr,
+			wantState:     CircuitOpen,
+			wantErr:       testErr,
+			wantFailCount: 1,
+			wantNotify:    []CircuitState{CircuitForcedOpen, CircuitClosed, CircuitClosed, CircuitOpen},
+		},
+		{
+			name:          "override to the current state is not notified",
+			state:         CircuitForcedOpen,
+			overrideFn:    (*CircuitBreaker[int]).ForceOpen,
+			wantState:     CircuitForcedOpen,
+			wantErr:       ErrCircuitOpen,
+			wantFailCount: 0,
+			wantNotify:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var notified []CircuitState
+
+			cb := NewCircuitBreaker[int](WithFailThreshold(1))
+			cb.state = tt.state
+			cb.notifyStateChangeFn = func(oldState, newState CircuitState) {
+				notified = append(notified, oldState, newState)
+			}
+			cb.scheduleRecoverFn = func() {}
+
+			tt.overrideFn(cb)
+
+			_, err := cb.Do(func() (int, error) { return 1, tt.err })
+
+			require.ErrorIs(t, err, tt.wantErr, "Do() - err = %v, wantErr = %v", err, tt.wantErr)
+			require.Equal(t, tt.wantState, cb.State(), "State() - got = %v, want = %v", cb.State(), tt.wantState)
+			require.Equal(t, tt.wantFailCount, cb.failCount,
+				"Do() - failCount = %v, want = %v", cb.failCount, tt.wantFailCount)
+			require.Equal(t, tt.wantNotify, notified,
+				"notifyStateChange() - got = %v, want = %v", notified, tt.wantNotify)
+		})
+	}
+}
+
+func TestCircuitBreaker_Close(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	cb := NewCircuitBreaker[int](WithFailThreshold(1), WithWaitInterval(time.Hour))
+
+	_, err := cb.Do(func() (int, error) { return 0, fmt.Errorf("test error") })
+	require.Error(t, err, "Do() - err = %v, want error", err)
+	require.Equal(t, CircuitOpen, cb.State(), "Do() - state = %v, want = %v", cb.State(), CircuitOpen)
+
+	cb.Close()
+	cb.Close()
+
+	_, err = cb.Do(func() (int, error) { return 1, nil })
+	require.ErrorIs(t, err, ErrBreakerClosed, "Do() - err = %v, wantErr = %v", err, ErrBreakerClosed)
+
+	doneCh := make(chan struct{})
+	go func() {
+		cb.processEvents()
+		cb.notifyStateChange(CircuitOpen, CircuitHalfOpen)
+		cb.scheduleRestore()
+		cb.restoreCircuit(restoreCircuitEvent{})
+		close(doneCh)
+	}()
+
+	select {
+	case <-doneCh:
+	case <-ctx.Done():
+		require.NoError(t, ctx.Err(), "Close() - err = %v, want no error", ctx.Err())
+	}
+}
+
+func TestNewCircuit_stateChangeFuncs(t *testing.T) {
+	t.Cleanup(func() { DefaultOptions() })
+
+	notifyCh := make(chan string, 3)
+	notifyFn := func(name string) StateChangeFunc {
+		return func(oldState, newState CircuitState) {
+			notifyCh <- name
+		}
+	}
+
+	// the state change function of the circuit replaces the default one
+	DefaultOptions(WithStateChangeFunc(notifyFn("default")))
+	cb := NewCircuit(WithStateChangeFunc(notifyFn("circuit")), WithAdditionalStateChangeFunc(notifyFn("additional")))
+	defer cb.Close()
+
+	require.Len(t, cb.settings().stateChangeListeners, 2,
+		"NewCircuit() - listeners = %v, want = %v", len(cb.settings().stateChangeListeners), 2)
+
+	cb.ForceOpen()
+
+	want := []string{"additional", "circuit"}
+	got := []string{<-notifyCh, <-notifyCh}
+	sort.Strings(got)
+	require.Equal(t, want, got, "notifyStateChange() - got = %v, want = %v", got, want)
+}
+
 func TestCircuitBreaker_notifyStateChange(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
 	defer cancel()
@@ -201,12 +1190,26 @@ func TestCircuitBreaker_notifyStateChange(t *testing.T) {
 	wantNewState := CircuitOpen
 
 	notifyCh := make(chan stateChangeEvent)
+	blockCh := make(chan struct{})
+	defer close(blockCh)
 
-	cb := CircuitBreaker[any]{
-		notifyStateChangeCh: notifyCh,
-	}
+	var cb Circuit
+	cb.current.Store(&settings{
+		stateChangeListeners: []*stateChangeListener{
+			newStateChangeListener(func(oldState, newState CircuitState) {
+				<-blockCh
+			}, 1),
+			newStateChangeListener(func(oldState, newState CircuitState) {
+				panic("test panic")
+			}, 1),
+			newStateChangeListener(func(oldState, newState CircuitState) {
+				notifyCh <- stateChangeEvent{oldState: oldState, newState: newState}
+			}, 1),
+		},
+	})
 
-	go cb.notifyStateChange(wantOldState, wantNewState)
+	// the blocked and panicking listeners must not prevent the notification
+	cb.notifyStateChange(wantOldState, wantNewState)
 
 	var got stateChangeEvent
 
@@ -222,24 +1225,59 @@ func TestCircuitBreaker_notifyStateChange(t *testing.T) {
 	require.Equal(t, wantNewState, got.newState, "notifyStateChange() - newState = %v, want = %v", got.newState, wantNewState)
 }
 
+func TestStateChangeListener_enqueue(t *testing.T) {
+	blockCh := make(chan struct{})
+	notifyCh := make(chan CircuitState, 3)
+
+	l := newStateChangeListener(func(oldState, newState CircuitState) {
+		<-blockCh
+		notifyCh <- newState
+	}, 1)
+
+	l.enqueue(stateChangeEvent{oldState: CircuitClosed, newState: CircuitOpen})
+
+	// wait for the first state change to be picked up by the delivery goroutine
+	require.Eventually(t, func() bool { return len(l.queue) == 0 }, time.Second, time.Millisecond,
+		"enqueue() - state change not delivered")
+
+	l.enqueue(stateChangeEvent{oldState: CircuitOpen, newState: CircuitHalfOpen})
+	l.enqueue(stateChangeEvent{oldState: CircuitHalfOpen, newState: CircuitClosed})
+	require.Equal(t, uint64(1), atomic.LoadUint64(&l.dropped),
+		"enqueue() - dropped = %v, want = %v", atomic.LoadUint64(&l.dropped), 1)
+
+	close(blockCh)
+
+	want := []CircuitState{CircuitOpen, CircuitHalfOpen}
+	got := []CircuitState{<-notifyCh, <-notifyCh}
+	require.Equal(t, want, got, "enqueue() - got = %v, want = %v", got, want)
+}
+
 func TestCircuitBreaker_scheduleRestore(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
 	defer cancel()
 
 	restoreCh := make(chan restoreCircuitEvent)
 
-	cb := CircuitBreaker[any]{
+	fakeClock := clock.NewFake(time.Unix(0, 0))
+	cb := Circuit{
+		clock:            fakeClock,
+		generation:       3,
 		restoreCircuitCh: restoreCh,
 	}
+	cb.current.Store(&settings{waitInterval: time.Second})
 
 	go cb.scheduleRestore()
 
+	var got restoreCircuitEvent
 	select {
-	case <-restoreCh:
+	case got = <-restoreCh:
 	case <-ctx.Done():
 		require.NoError(t, ctx.Err(), "scheduleRestore() - err = %v, want no error", ctx.Err())
 		return
 	}
+
+	want := restoreCircuitEvent{generation: 3}
+	require.Equal(t, want, got, "scheduleRestore() - got = %v, want = %v", got, want)
 }
 
 func Test_recordFailure(t *testing.T) {
@@ -309,7 +1347,7 @@ func Test_recordFailure(t *testing.T) {
 			cb.successCount = tt.successCount
 			cb.notifyStateChangeFn = notifyFn
 
-			cb.recordFailure()
+			cb.recordFailure(0)
 
 			require.Equal(t, tt.wantState, cb.state,
 				"recordFailure() - state = %v, want = %v", cb.state, tt.wantState)
@@ -323,6 +1361,187 @@ func Test_recordFailure(t *testing.T) {
 	}
 }
 
+func TestCircuitBreaker_failureRate(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  []bool
+		wantState CircuitState
+	}{
+		{
+			name:      "window not full",
+			failures:  []bool{true, true, true, true},
+			wantState: CircuitClosed,
+		},
+		{
+			name:      "failure rate below threshold",
+			failures:  []bool{true, false, true, false, false},
+			wantState: CircuitClosed,
+		},
+		{
+			name:      "failure rate reaches threshold with interleaved successes",
+			failures:  []bool{true, false, true, false, true},
+			wantState: CircuitOpen,
+		},
+		{
+			name:      "failure rate over the last executions only",
+			failures:  []bool{false, false, false, false, false, true, false, true, true},
+			wantState: CircuitOpen,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			notifyCount := 0
+			notifyFn := func(oldState, newState CircuitState) {
+				notifyCount++
+			}
+
+			cb := NewCircuitBreaker[any](WithFailureRateThreshold(60, 5))
+			cb.notifyStateChangeFn = notifyFn
+			cb.scheduleRecoverFn = func() {}
+
+			for _, failure := range tt.failures {
+				if failure {
+					cb.recordFailure(0)
+					continue
+				}
+				cb.recordSuccess(0)
+			}
+
+			wantNotifyCount := 0
+			if tt.wantState == CircuitOpen {
+				wantNotifyCount = 1
+			}
+
+			require.Equal(t, tt.wantState, cb.state,
+				"failureRate() - state = %v, want = %v", cb.state, tt.wantState)
+			require.Equal(t, wantNotifyCount, notifyCount,
+				"failureRate() - notifyCount = %v, want = %v", notifyCount, wantNotifyCount)
+		})
+	}
+}
+
+func TestCircuitBreaker_rollingFailureRate(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []Option
+		failures  []bool
+		wantState CircuitState
+	}{
+		{
+			name:      "below the default minimum requests",
+			opts:      []Option{WithRollingFailureRateThreshold(50, 10, time.Second)},
+			failures:  []bool{true, true},
+			wantState: CircuitClosed,
+		},
+		{
+			name:      "failure rate reached with the default minimum requests",
+			opts:      []Option{WithRollingFailureRateThreshold(50, 4, time.Second)},
+			failures:  []bool{true, false, true, false},
+			wantState: CircuitOpen,
+		},
+		{
+			name:      "below minimum requests",
+			opts:      []Option{WithRollingFailureRateThreshold(50, 10, time.Second), WithMinimumRequests(5)},
+			failures:  []bool{true, true, false, true},
+			wantState: CircuitClosed,
+		},
+		{
+			name:      "failure rate reached with minimum requests",
+			opts:      []Option{WithRollingFailureRateThreshold(50, 10, time.Second), WithMinimumRequests(5)},
+			failures:  []bool{true, true, false, false, true},
+			wantState: CircuitOpen,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			cb := NewCircuitBreaker[any](tt.opts...)
+			cb.notifyStateChangeFn = func(oldState, newState CircuitState) {}
+			cb.scheduleRecoverFn = func() {}
+
+			for _, failure := range tt.failures {
+				if failure {
+					cb.recordFailure(0)
+					continue
+				}
+				cb.recordSuccess(0)
+			}
+
+			require.Equal(t, tt.wantState, cb.state,
+				"rollingFailureRate() - state = %v, want = %v", cb.state, tt.wantState)
+		})
+	}
+}
+
+func TestCircuitBreaker_slowCallRate(t *testing.T) {
+	type call struct {
+		failure bool
+		elapsed time.Duration
+	}
+
+	fast := call{elapsed: 10 * time.Millisecond}
+	slow := call{elapsed: 200 * time.Millisecond}
+	slowFailure := call{failure: true, elapsed: 200 * time.Millisecond}
+
+	tests := []struct {
+		name      string
+		opts      []Option
+		calls     []call
+		wantState CircuitState
+	}{
+		{
+			name:      "slow call rate below threshold",
+			opts:      []Option{WithSlowCallThreshold(100*time.Millisecond, 50), WithMinimumRequests(4)},
+			calls:     []call{slow, fast, fast, fast},
+			wantState: CircuitClosed,
+		},
+		{
+			name:      "slow successful calls trip the circuit",
+			opts:      []Option{WithSlowCallThreshold(100*time.Millisecond, 50), WithMinimumRequests(4)},
+			calls:     []call{slow, fast, slow, fast},
+			wantState: CircuitOpen,
+		},
+		{
+			name:      "below minimum requests",
+			opts:      []Option{WithSlowCallThreshold(100*time.Millisecond, 50), WithMinimumRequests(4)},
+			calls:     []call{slow, slow, slow},
+			wantState: CircuitClosed,
+		},
+		{
+			name: "slow failed calls trip the circuit independently from failure rate",
+			opts: []Option{
+				WithFailureRateThreshold(100, 4),
+				WithSlowCallThreshold(100*time.Millisecond, 50),
+			},
+			calls:     []call{slowFailure, fast, slowFailure, fast},
+			wantState: CircuitOpen,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			cb := NewCircuitBreaker[any](tt.opts...)
+			cb.notifyStateChangeFn = func(oldState, newState CircuitState) {}
+			cb.scheduleRecoverFn = func() {}
+
+			for _, c := range tt.calls {
+				if c.failure {
+					cb.recordFailure(c.elapsed)
+					continue
+				}
+				cb.recordSuccess(c.elapsed)
+			}
+
+			require.Equal(t, tt.wantState, cb.state,
+				"slowCallRate() - state = %v, want = %v", cb.state, tt.wantState)
+		})
+	}
+}
+
 func TestCircuitBreaker_recordSuccess(t *testing.T) {
 	tests := []struct {
 		name             string
@@ -390,7 +1609,7 @@ func TestCircuitBreaker_recordSuccess(t *testing.T) {
 			cb.successCount = tt.successCount
 			cb.notifyStateChangeFn = notifyFn
 
-			cb.recordSuccess()
+			cb.recordSuccess(0)
 
 			require.Equal(t, tt.wantState, cb.state,
 				"recordSuccess() - state = %v, want = %v", cb.state, tt.wantState)
@@ -404,6 +1623,178 @@ func TestCircuitBreaker_recordSuccess(t *testing.T) {
 	}
 }
 
+func TestCircuitBreaker_openInterval(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        []Option
+		reopenCount int32
+		wantMin     time.Duration
+		wantMax     time.Duration
+	}{
+		{
+			name:        "fixed wait interval",
+			opts:        []Option{WithWaitInterval(time.Second)},
+			reopenCount: 3,
+			wantMin:     time.Second,
+			wantMax:     time.Second,
+		},
+		{
+			name:        "backoff initial wait interval",
+			opts:        []Option{WithWaitIntervalBackoff(time.Second, 2, time.Minute)},
+			reopenCount: 0,
+			wantMin:     time.Second,
+			wantMax:     time.Second,
+		},
+		{
+			name:        "backoff after repeated trips",
+			opts:        []Option{WithWaitIntervalBackoff(time.Second, 2, time.Minute)},
+			reopenCount: 3,
+			wantMin:     8 * time.Second,
+			wantMax:     8 * time.Second,
+		},
+		{
+			name:        "backoff capped to max wait interval",
+			opts:        []Option{WithWaitIntervalBackoff(time.Second, 2, time.Minute)},
+			reopenCount: 10,
+			wantMin:     time.Minute,
+			wantMax:     time.Minute,
+		},
+		{
+			name:        "jitter",
+			opts:        []Option{WithWaitInterval(10 * time.Second), WithWaitIntervalJitter(0.1)},
+			reopenCount: 0,
+			wantMin:     9 * time.Second,
+			wantMax:     11 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			cb := NewCircuitBreaker[any](tt.opts...)
+			cb.reopenCount = tt.reopenCount
+
+			got := cb.openInterval(cb.reopenCount)
+			require.GreaterOrEqual(t, got, tt.wantMin, "openInterval() - got = %v, want >= %v", got, tt.wantMin)
+			require.LessOrEqual(t, got, tt.wantMax, "openInterval() - got = %v, want <= %v", got, tt.wantMax)
+		})
+	}
+}
+
+func TestCircuit_Reconfigure(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        []Option
+		before      []bool
+		reconfigure []Option
+		after       []bool
+		wantState   CircuitState
+	}{
+		{
+			name:        "lower fail threshold keeps failures",
+			opts:        []Option{WithFailThreshold(5)},
+			before:      []bool{true, true},
+			reconfigure: []Option{WithFailThreshold(3)},
+			after:       []bool{true},
+			wantState:   CircuitOpen,
+		},
+		{
+			name:        "higher fail threshold",
+			opts:        []Option{WithFailThreshold(3)},
+			before:      []bool{true, true},
+			reconfigure: []Option{WithFailThreshold(5)},
+			after:       []bool{true},
+			wantState:   CircuitClosed,
+		},
+		{
+			name:        "window preserved",
+			opts:        []Option{WithFailureRateThreshold(60, 5)},
+			before:      []bool{true, true, false, false},
+			reconfigure: []Option{WithFailureRateThreshold(50, 5)},
+			after:       []bool{true},
+			wantState:   CircuitOpen,
+		},
+		{
+			name:        "window replaced on size change",
+			opts:        []Option{WithFailureRateThreshold(60, 5)},
+			before:      []bool{true, true, false, false},
+			reconfigure: []Option{WithFailureRateThreshold(50, 4)},
+			after:       []bool{true},
+			wantState:   CircuitClosed,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			cb := NewCircuitBreaker[any](tt.opts...)
+			cb.notifyStateChangeFn = func(oldState, newState CircuitState) {}
+			cb.scheduleRecoverFn = func() {}
+
+			record := func(failures []bool) {
+				for _, failure := range failures {
+					if failure {
+						cb.recordFailure(0)
+						continue
+					}
+					cb.recordSuccess(0)
+				}
+			}
+
+			record(tt.before)
+			cb.Reconfigure(tt.reconfigure...)
+			record(tt.after)
+
+			require.Equal(t, tt.wantState, cb.state,
+				"Reconfigure() - state = %v, want = %v", cb.state, tt.wantState)
+		})
+	}
+}
+
+func TestCircuit_Reconfigure_running(t *testing.T) {
+	testErr := fmt.Errorf("test error")
+
+	cb := NewCircuitBreaker[int](WithFailThreshold(1), WithWaitInterval(time.Hour), WithLazyRestore(true))
+	_, _ = cb.Do(func() (int, error) { return 0, testErr })
+
+	fallback := FallbackFunc[int](func(err error) (int, error) { return -1, nil })
+	cb.Reconfigure(WithFailThreshold(3), WithSuccessThreshold(2), WithFallback(fallback))
+
+	require.Equal(t, CircuitOpen, cb.State(), "Reconfigure() - state = %v, want = %v", cb.State(), CircuitOpen)
+	require.Equal(t, int32(3), cb.settings().failThreshold,
+		"Reconfigure() - failThreshold = %v, want = %v", cb.settings().failThreshold, 3)
+	require.Equal(t, int32(2), cb.settings().successThreshold,
+		"Reconfigure() - successThreshold = %v, want = %v", cb.settings().successThreshold, 2)
+	require.Equal(t, _defaultWaitInterval, cb.settings().waitInterval,
+		"Reconfigure() - waitInterval = %v, want = %v", cb.settings().waitInterval, _defaultWaitInterval)
+
+	got, err := cb.Do(func() (int, error) { return 1, nil })
+	require.NoError(t, err, "Do() - err = %v, want no error", err)
+	require.Equal(t, -1, got, "Do() - got = %v, want = %v", got, -1)
+
+	stats := cb.Stats()
+	require.Equal(t, uint64(1), stats.Failures, "Stats() - Failures = %v, want = %v", stats.Failures, 1)
+	require.Equal(t, uint64(1), stats.Rejections, "Stats() - Rejections = %v, want = %v", stats.Rejections, 1)
+}
+
+func TestCircuitBreaker_reopenCount(t *testing.T) {
+	cb := NewCircuitBreaker[any](WithSuccessThreshold(1))
+	cb.notifyStateChangeFn = func(oldState, newState CircuitState) {}
+	cb.scheduleRecoverFn = func() {}
+
+	for i := 1; i <= 2; i++ {
+		cb.state = CircuitHalfOpen
+		cb.recordFailure(0)
+		require.Equal(t, int32(i), cb.reopenCount,
+			"recordFailure() - reopenCount = %v, want = %v", cb.reopenCount, i)
+	}
+
+	cb.state = CircuitHalfOpen
+	cb.recordSuccess(0)
+	require.Equal(t, int32(0), cb.reopenCount,
+		"recordSuccess() - reopenCount = %v, want = %v", cb.reopenCount, 0)
+}
+
 func Test_restoreCircuit(t *testing.T) {
 	tests := []struct {
 		name             string
@@ -464,7 +1855,8 @@ func Test_restoreCircuit(t *testing.T) {
 			cb.notifyStateChangeFn = notifyFn
 
 			startTime := time.Now()
-			cb.restoreCircuit()
+			cb.openUntil = startTime.Add(waitTime).UnixNano()
+			cb.restoreCircuit(restoreCircuitEvent{generation: cb.generation})
 			elapsed := time.Since(startTime)
 
 			require.GreaterOrEqual(t, elapsed, waitTime,
@@ -0,0 +1,522 @@
+package breaker
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrInvalidConfig is returned when a declarative configuration cannot be parsed or validated.
+var ErrInvalidConfig = errors.New("invalid circuit configuration")
+
+// Duration is a time.Duration represented in JSON by a string, such as "30s" or "1m30s".
+type Duration time.Duration
+
+// MarshalJSON implements the json.Marshaler interface.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return &durationError{msg: "duration must be a string, such as \"30s\""}
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return &durationError{msg: fmt.Sprintf("invalid duration %q", s)}
+	}
+	*d = Duration(v)
+
+	return nil
+}
+
+// durationError is the error returned for a duration that cannot be parsed.
+// The decoder does not report the field of the errors returned by json.Unmarshaler,
+// so it is located by ParseConfig, see locateInvalidDuration.
+type durationError struct {
+	msg string
+}
+
+// Error implements the error interface.
+func (e *durationError) Error() string {
+	return e.msg
+}
+
+// Config represents the declarative configuration of a set of named circuits.
+type Config struct {
+	// Defaults are the settings applied to all the circuits, unless overridden.
+	Defaults CircuitConfig `json:"defaults"`
+
+	// Circuits are the settings of the named circuits.
+	Circuits map[string]CircuitConfig `json:"circuits"`
+}
+
+// CircuitConfig represents the declarative configuration of a circuit.
+// The unset fields keep the value of the defaults.
+type CircuitConfig struct {
+	FailThreshold       *int               `json:"failThreshold,omitempty"`
+	FailureRate         *FailureRateConfig `json:"failureRate,omitempty"`
+	HalfOpenMaxCalls    *int               `json:"halfOpenMaxCalls,omitempty"`
+	IgnoreContextErrors *bool              `json:"ignoreContextErrors,omitempty"`
+	LazyRestore         *bool              `json:"lazyRestore,omitempty"`
+	MinimumRequests     *int               `json:"minimumRequests,omitempty"`
+	PanicPolicy         *string            `json:"panicPolicy,omitempty"`
+	SlowCall            *SlowCallConfig    `json:"slowCall,omitempty"`
+	SuccessThreshold    *int               `json:"successThreshold,omitempty"`
+	WaitInterval        *Duration          `json:"waitInterval,omitempty"`
+	WaitIntervalBackoff *BackoffConfig     `json:"waitIntervalBackoff,omitempty"`
+	WaitIntervalJitter  *float64           `json:"waitIntervalJitter,omitempty"`
+}
+
+// FailureRateConfig represents the declarative configuration of the failure rate based tripping.
+// See WithFailureRateThreshold and WithRollingFailureRateThreshold.
+type FailureRateConfig struct {
+	// Threshold is the percentage of failures tripping the circuit.
+	Threshold float64 `json:"threshold"`
+
+	// Window is either "count", the default, or "time" for a time based rolling window.
+	Window string `json:"window,omitempty"`
+
+	// Size is the number of executions of a count based window, or the number of buckets of a time based window.
+	Size int `json:"size"`
+
+	// BucketWidth is the time spanned by each bucket of a time based window.
+	BucketWidth Duration `json:"bucketWidth,omitempty"`
+}
+
+// SlowCallConfig represents the declarative configuration of the slow call rate based tripping.
+// See WithSlowCallThreshold.
+type SlowCallConfig struct {
+	// Threshold is the duration after which an execution is considered slow.
+	Threshold Duration `json:"threshold"`
+
+	// Rate is the percentage of slow calls tripping the circuit.
+	Rate float64 `json:"rate"`
+}
+
+// BackoffConfig represents the declarative configuration of the exponential growth of the wait interval.
+// See WithWaitIntervalBackoff.
+type BackoffConfig struct {
+	// Multiplier is the factor applied to the wait interval each time a recovery attempt fails.
+	Multiplier float64 `json:"multiplier"`
+
+	// MaxInterval is the maximum wait interval.
+	MaxInterval Duration `json:"maxInterval"`
+}
+
+// LoadConfig reads a JSON configuration of named circuits and registers them in the default registry.
+// See Registry.LoadConfig for details.
+func LoadConfig(r io.Reader) error {
+	return _registry.LoadConfig(r)
+}
+
+// LoadConfigFile reads a JSON configuration file of named circuits and registers them in the default registry.
+// See Registry.LoadConfig for details.
+func LoadConfigFile(path string) error {
+	return _registry.LoadConfigFile(path)
+}
+
+// LoadConfig reads a JSON configuration of named circuits and registers them in the registry.
+// The defaults of the configuration are applied to the configured circuits and to the circuits
+// created lazily by the registry afterwards.
+// The configuration is validated before any circuit is registered, and no circuit is registered
+// if any of them already exists. The returned error matches ErrInvalidConfig when the configuration
+// is not valid.
+func (r *Registry) LoadConfig(rd io.Reader) error {
+	cfg, err := ParseConfig(rd)
+	if err != nil {
+		return err
+	}
+
+	return r.apply(cfg)
+}
+
+// LoadConfigFile reads a JSON configuration file of named circuits and registers them in the registry.
+// See LoadConfig for details.
+func (r *Registry) LoadConfigFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return r.LoadConfig(f)
+}
+
+// ParseConfig reads and validates a JSON configuration of named circuits.
+// Unknown fields are rejected. A configuration that is not valid is reported by a ConfigError
+// listing all the invalid settings, prefixed by their path.
+func ParseConfig(rd io.Reader) (*Config, error) {
+	data, err := io.ReadAll(rd)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	var cfg Config
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidConfig, describeJSONError(data, err))
+	}
+
+	if fields := cfg.validate(); len(fields) > 0 {
+		return nil, &ConfigError{Fields: fields}
+	}
+
+	return &cfg, nil
+}
+
+// apply registers the circuits of the configuration in the registry,
+// once their options merged with the defaults have been validated.
+func (r *Registry) apply(cfg *Config) error {
+	names := make([]string, 0, len(cfg.Circuits))
+	for name := range cfg.Circuits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	defaultOpts := cfg.Defaults.Options()
+	configs, err := circuitConfigs(defaultOpts, cfg, names)
+	if err != nil {
+		return err
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, name := range names {
+		if _, exists := r.circuits[name]; exists {
+			return fmt.Errorf("%w: %s", ErrDuplicateCircuit, name)
+		}
+	}
+
+	r.defaultOpts = defaultOpts
+	for _, name := range names {
+		r.circuits[name] = &entry{circuit: newCircuit(configs[name])}
+	}
+
+	return nil
+}
+
+// circuitConfigs returns the validated configurations of the named circuits, merged with the default options.
+// A ConfigError listing the invalid settings of all the circuits, prefixed by their path, is returned
+// if any of them is not valid.
+func circuitConfigs(defaultOpts []Option, cfg *Config, names []string) (map[string]config, error) {
+	configs := make(map[string]config, len(names))
+
+	var fields []FieldError
+	for _, name := range names {
+		c, err := validatedConfig(circuitOpts(defaultOpts, name, cfg.Circuits[name].Options()))
+		if err != nil {
+			configErr, ok := err.(*ConfigError)
+			if !ok {
+				return nil, err
+			}
+
+			for _, f := range configErr.Fields {
+				fields = append(fields, FieldError{Field: fmt.Sprintf("circuits.%s.%s", name, f.Field), Reason: f.Reason})
+			}
+			continue
+		}
+		configs[name] = c
+	}
+
+	if len(fields) > 0 {
+		return nil, &ConfigError{Fields: fields}
+	}
+
+	return configs, nil
+}
+
+// Options returns the options corresponding to the configuration of the circuit.
+func (c CircuitConfig) Options() []Option {
+	var opts []Option
+
+	if c.FailThreshold != nil {
+		opts = append(opts, WithFailThreshold(*c.FailThreshold))
+	}
+	if c.SuccessThreshold != nil {
+		opts = append(opts, WithSuccessThreshold(*c.SuccessThreshold))
+	}
+	if c.HalfOpenMaxCalls != nil {
+		opts = append(opts, WithHalfOpenMaxCalls(*c.HalfOpenMaxCalls))
+	}
+	if c.IgnoreContextErrors != nil {
+		opts = append(opts, WithIgnoreContextErrors(*c.IgnoreContextErrors))
+	}
+	if c.LazyRestore != nil {
+		opts = append(opts, WithLazyRestore(*c.LazyRestore))
+	}
+	if c.PanicPolicy != nil {
+		policy, _ := parsePanicPolicy(*c.PanicPolicy)
+		opts = append(opts, WithPanicPolicy(policy))
+	}
+	if c.FailureRate != nil {
+		if c.FailureRate.Window == "time" {
+			opts = append(opts, WithRollingFailureRateThreshold(c.FailureRate.Threshold, c.FailureRate.Size,
+				time.Duration(c.FailureRate.BucketWidth)))
+		} else {
+			opts = append(opts, WithFailureRateThreshold(c.FailureRate.Threshold, c.FailureRate.Size))
+		}
+	}
+	if c.MinimumRequests != nil {
+		opts = append(opts, WithMinimumRequests(*c.MinimumRequests))
+	}
+	if c.SlowCall != nil {
+		opts = append(opts, WithSlowCallThreshold(time.Duration(c.SlowCall.Threshold), c.SlowCall.Rate))
+	}
+	if c.WaitInterval != nil {
+		opts = append(opts, WithWaitInterval(time.Duration(*c.WaitInterval)))
+	}
+	if c.WaitIntervalBackoff != nil {
+		backoff := *c.WaitIntervalBackoff
+		opts = append(opts, func(cfg *config) {
+			cfg.maxWaitInterval = time.Duration(backoff.MaxInterval)
+			cfg.waitMultiplier = backoff.Multiplier
+		})
+	}
+	if c.WaitIntervalJitter != nil {
+		opts = append(opts, WithWaitIntervalJitter(*c.WaitIntervalJitter))
+	}
+
+	return opts
+}
+
+// validate returns the invalid settings of the configuration, sorted by circuit name.
+func (c *Config) validate() []FieldError {
+	fields := c.Defaults.validate("defaults")
+
+	names := make([]string, 0, len(c.Circuits))
+	for name := range c.Circuits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if len(strings.TrimSpace(name)) == 0 {
+			fields = append(fields, FieldError{Field: fmt.Sprintf("circuits[%q]", name), Reason: ErrRequiredName.Error()})
+			continue
+		}
+		fields = append(fields, c.Circuits[name].validate(fmt.Sprintf("circuits.%s", name))...)
+	}
+
+	return fields
+}
+
+// validate returns the invalid settings of the configuration of a circuit, prefixed by its path.
+func (c CircuitConfig) validate(path string) []FieldError {
+	var fields []FieldError
+	addf := func(field, format string, args ...any) {
+		fields = append(fields, FieldError{Field: path + "." + field, Reason: fmt.Sprintf(format, args...)})
+	}
+
+	if c.FailThreshold != nil && *c.FailThreshold <= 0 {
+		addf("failThreshold", "must be greater than 0, got %d", *c.FailThreshold)
+	}
+	if c.SuccessThreshold != nil && *c.SuccessThreshold <= 0 {
+		addf("successThreshold", "must be greater than 0, got %d", *c.SuccessThreshold)
+	}
+	if c.HalfOpenMaxCalls != nil && *c.HalfOpenMaxCalls < 0 {
+		addf("halfOpenMaxCalls", "must not be negative, got %d", *c.HalfOpenMaxCalls)
+	}
+	if c.MinimumRequests != nil && *c.MinimumRequests < 0 {
+		addf("minimumRequests", "must not be negative, got %d", *c.MinimumRequests)
+	}
+	if c.PanicPolicy != nil {
+		if _, ok := parsePanicPolicy(*c.PanicPolicy); !ok {
+			addf("panicPolicy", "must be one of \"recover\", \"report\" or \"repanic\", got %q", *c.PanicPolicy)
+		}
+	}
+	if c.WaitInterval != nil && *c.WaitInterval <= 0 {
+		addf("waitInterval", "must be greater than 0, got %s", time.Duration(*c.WaitInterval))
+	}
+	if c.WaitIntervalJitter != nil && (*c.WaitIntervalJitter < 0 || *c.WaitIntervalJitter > 1) {
+		addf("waitIntervalJitter", "must be between 0 and 1, got %v", *c.WaitIntervalJitter)
+	}
+
+	if b := c.WaitIntervalBackoff; b != nil {
+		if b.Multiplier < 1 {
+			addf("waitIntervalBackoff.multiplier", "must be at least 1, got %v", b.Multiplier)
+		}
+		if b.MaxInterval < 0 {
+			addf("waitIntervalBackoff.maxInterval", "must not be negative, got %s", time.Duration(b.MaxInterval))
+		}
+	}
+
+	if fr := c.FailureRate; fr != nil {
+		if fr.Threshold <= 0 || fr.Threshold > 100 {
+			addf("failureRate.threshold", "must be greater than 0 and at most 100, got %v", fr.Threshold)
+		}
+		if fr.Size <= 0 {
+			addf("failureRate.size", "must be greater than 0, got %d", fr.Size)
+		}
+
+		switch fr.Window {
+		case "", "count":
+			if fr.BucketWidth != 0 {
+				addf("failureRate.bucketWidth", "only allowed for a \"time\" window")
+			}
+		case "time":
+			if fr.BucketWidth <= 0 {
+				addf("failureRate.bucketWidth", "must be greater than 0, got %s", time.Duration(fr.BucketWidth))
+			}
+		default:
+			addf("failureRate.window", "must be either \"count\" or \"time\", got %q", fr.Window)
+		}
+	}
+
+	if sc := c.SlowCall; sc != nil {
+		if sc.Threshold <= 0 {
+			addf("slowCall.threshold", "must be greater than 0, got %s", time.Duration(sc.Threshold))
+		}
+		if sc.Rate <= 0 || sc.Rate > 100 {
+			addf("slowCall.rate", "must be greater than 0 and at most 100, got %v", sc.Rate)
+		}
+	}
+
+	return fields
+}
+
+// parsePanicPolicy returns the panic policy corresponding to its string representation.
+func parsePanicPolicy(s string) (PanicPolicy, bool) {
+	for _, p := range []PanicPolicy{PanicRecover, PanicReport, PanicRepanic} {
+		if p.String() == s {
+			return p, true
+		}
+	}
+
+	return PanicRecover, false
+}
+
+// describeJSONError returns a description of a decoding error, including its position when available.
+func describeJSONError(data []byte, err error) string {
+	var (
+		durationErr *durationError
+		syntaxErr   *json.SyntaxError
+		typeErr     *json.UnmarshalTypeError
+	)
+
+	switch {
+	case errors.As(err, &durationErr):
+		if path, offset, ok := locateInvalidDuration(data); ok {
+			line, col := position(data, offset)
+			return fmt.Sprintf("line %d, column %d: %s: %s", line, col, path, durationErr)
+		}
+	case errors.As(err, &syntaxErr):
+		line, col := position(data, syntaxErr.Offset)
+		return fmt.Sprintf("line %d, column %d: %s", line, col, syntaxErr)
+	case errors.As(err, &typeErr):
+		line, col := position(data, typeErr.Offset)
+		return fmt.Sprintf("line %d, column %d: %s must be %s, got %s", line, col, typeErr.Field, typeErr.Type, typeErr.Value)
+	}
+
+	return err.Error()
+}
+
+// locateInvalidDuration returns the path and the offset of the value of the first duration field of the data
+// that cannot be parsed, in the same order the fields are decoded.
+func locateInvalidDuration(data []byte) (path string, offset int64, ok bool) {
+	type frame struct {
+		object    bool
+		key       string
+		expectKey bool
+	}
+
+	var stack []*frame
+	keys := func() []string {
+		keys := make([]string, 0, len(stack))
+		for _, f := range stack {
+			if f.object {
+				keys = append(keys, f.key)
+			}
+		}
+		return keys
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		start := dec.InputOffset()
+		tok, err := dec.Token()
+		if err != nil {
+			return "", 0, false
+		}
+
+		var top *frame
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+
+		if delim, isDelim := tok.(json.Delim); isDelim {
+			switch delim {
+			case '{', '[':
+				stack = append(stack, &frame{object: delim == '{', expectKey: delim == '{'})
+			default:
+				stack = stack[:len(stack)-1]
+				if len(stack) > 0 && stack[len(stack)-1].object {
+					stack[len(stack)-1].expectKey = true
+				}
+			}
+			continue
+		}
+
+		if top == nil || !top.object {
+			continue
+		}
+
+		if top.expectKey {
+			top.key, _ = tok.(string)
+			top.expectKey = false
+			continue
+		}
+		top.expectKey = true
+
+		if path := keys(); isDurationField(path) {
+			s, isString := tok.(string)
+			if _, err := time.ParseDuration(s); !isString || err != nil {
+				// skip the separator preceding the value
+				for start < int64(len(data)) && bytes.IndexByte([]byte(" \t\r\n:"), data[start]) >= 0 {
+					start++
+				}
+				return strings.Join(path, "."), start, true
+			}
+		}
+	}
+}
+
+// isDurationField reports whether the path refers to a Duration field of the configuration.
+func isDurationField(path []string) bool {
+	if len(path) == 0 {
+		return false
+	}
+
+	switch path[len(path)-1] {
+	case "waitInterval", "bucketWidth", "maxInterval":
+		return true
+	case "threshold":
+		return len(path) > 1 && path[len(path)-2] == "slowCall"
+	}
+
+	return false
+}
+
+// position returns the line and column of an offset of the data.
+func position(data []byte, offset int64) (line, col int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+
+	line = 1 + bytes.Count(data[:offset], []byte("\n"))
+	col = int(offset) - bytes.LastIndexByte(data[:offset], '\n')
+
+	return line, col
+}
@@ -0,0 +1,187 @@
+package breaker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		wantFn    func(t *testing.T, cfg *Config)
+		wantErrs  []string
+		wantNoErr bool
+	}{
+		{
+			name: "valid",
+			data: `{
+				"defaults": {"failThreshold": 5, "waitInterval": "10s"},
+				"circuits": {
+					"users": {"failThreshold": 3},
+					"orders": {
+						"failureRate": {"threshold": 50, "window": "time", "size": 10, "bucketWidth": "1s"},
+						"slowCall": {"threshold": "2s", "rate": 80},
+						"waitIntervalBackoff": {"multiplier": 2, "maxInterval": "1m"},
+						"panicPolicy": "report"
+					}
+				}
+			}`,
+			wantFn: func(t *testing.T, cfg *Config) {
+				require.Equal(t, 5, *cfg.Defaults.FailThreshold,
+					"ParseConfig() - defaults.failThreshold = %v, want = %v", *cfg.Defaults.FailThreshold, 5)
+				require.Equal(t, Duration(10*time.Second), *cfg.Defaults.WaitInterval,
+					"ParseConfig() - defaults.waitInterval = %v, want = %v", *cfg.Defaults.WaitInterval, 10*time.Second)
+				require.Len(t, cfg.Circuits, 2, "ParseConfig() - circuits = %v, want 2", len(cfg.Circuits))
+				require.Equal(t, Duration(time.Second), cfg.Circuits["orders"].FailureRate.BucketWidth,
+					"ParseConfig() - bucketWidth = %v, want = %v", cfg.Circuits["orders"].FailureRate.BucketWidth, time.Second)
+			},
+			wantNoErr: true,
+		},
+		{
+			name:     "syntax error",
+			data:     "{\n  \"circuits\": {\n    \"users\": {\"failThreshold\": 3,}\n  }\n}",
+			wantErrs: []string{"line 3, column"},
+		},
+		{
+			name:     "wrong type",
+			data:     `{"circuits": {"users": {"failThreshold": "3"}}}`,
+			wantErrs: []string{"circuits.users.failThreshold must be int, got string"},
+		},
+		{
+			name:     "unknown field",
+			data:     `{"circuits": {"users": {"failTreshold": 3}}}`,
+			wantErrs: []string{`unknown field "failTreshold"`},
+		},
+		{
+			name:     "invalid duration",
+			data:     `{"circuits": {"users": {"waitInterval": "10"}}}`,
+			wantErrs: []string{`line 1, column 41: circuits.users.waitInterval: invalid duration "10"`},
+		},
+		{
+			name:     "invalid nested duration",
+			data:     "{\n  \"defaults\": {\"waitInterval\": \"1s\"},\n  \"circuits\": {\"users\": {\"slowCall\": {\"threshold\": 5}}}\n}",
+			wantErrs: []string{"line 3, column 52: circuits.users.slowCall.threshold: duration must be a string"},
+		},
+		{
+			name: "invalid values",
+			data: `{
+				"defaults": {"waitInterval": "0s"},
+				"circuits": {
+					"users": {"failThreshold": 0, "successThreshold": -1, "panicPolicy": "ignore"},
+					"orders": {"failureRate": {"threshold": 150, "window": "sliding", "size": 0}}
+				}
+			}`,
+			wantErrs: []string{
+				"defaults.waitInterval: must be greater than 0, got 0s",
+				"circuits.orders.failureRate.threshold: must be greater than 0 and at most 100, got 150",
+				"circuits.orders.failureRate.size: must be greater than 0, got 0",
+				`circuits.orders.failureRate.window: must be either "count" or "time", got "sliding"`,
+				"circuits.users.failThreshold: must be greater than 0, got 0",
+				"circuits.users.successThreshold: must be greater than 0, got -1",
+				`circuits.users.panicPolicy: must be one of "recover", "report" or "repanic", got "ignore"`,
+			},
+		},
+		{
+			name:     "missing bucket width",
+			data:     `{"circuits": {"users": {"failureRate": {"threshold": 50, "window": "time", "size": 10}}}}`,
+			wantErrs: []string{"circuits.users.failureRate.bucketWidth: must be greater than 0, got 0s"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseConfig(strings.NewReader(tt.data))
+			if tt.wantNoErr {
+				require.NoError(t, err, "ParseConfig() - err = %v, want no error", err)
+				tt.wantFn(t, got)
+				return
+			}
+
+			require.ErrorIs(t, err, ErrInvalidConfig, "ParseConfig() - err = %v, wantErr = %v", err, ErrInvalidConfig)
+			for _, want := range tt.wantErrs {
+				require.Contains(t, err.Error(), want, "ParseConfig() - err = %v, want = %v", err, want)
+			}
+		})
+	}
+}
+
+func TestRegistry_LoadConfig(t *testing.T) {
+	r := NewRegistry()
+
+	err := r.LoadConfig(strings.NewReader(`{
+		"defaults": {"failThreshold": 2, "waitInterval": "1h"},
+		"circuits": {
+			"users": {"failThreshold": 1},
+			"orders": {}
+		}
+	}`))
+	require.NoError(t, err, "LoadConfig() - err = %v, want no error", err)
+
+	tests := []struct {
+		name          string
+		wantFails     int
+		wantThreshold int32
+	}{
+		{name: "users", wantFails: 1, wantThreshold: 1},
+		{name: "orders", wantFails: 2, wantThreshold: 2},
+		{name: "lazy", wantFails: 2, wantThreshold: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := r.Circuit(tt.name)
+			require.Equal(t, tt.name, c.name, "Circuit() - name = %v, want = %v", c.name, tt.name)
+			require.Equal(t, tt.wantThreshold, c.settings().failThreshold,
+				"Circuit() - failThreshold = %v, want = %v", c.settings().failThreshold, tt.wantThreshold)
+			require.Equal(t, time.Hour, c.settings().waitInterval, "Circuit() - waitInterval = %v, want = %v", c.settings().waitInterval, time.Hour)
+		})
+	}
+
+	err = r.LoadConfig(strings.NewReader(`{"circuits": {"payments": {}, "users": {}}}`))
+	require.ErrorIs(t, err, ErrDuplicateCircuit, "LoadConfig() - err = %v, wantErr = %v", err, ErrDuplicateCircuit)
+	require.NotContains(t, r.Stats(), "payments", "LoadConfig() - payments registered after error")
+}
+
+func TestRegistry_LoadConfig_invalidMergedOptions(t *testing.T) {
+	r := NewRegistry()
+
+	// the options are only invalid once merged with the default options
+	t.Cleanup(func() { DefaultOptions() })
+	DefaultOptions(WithSuccessThreshold(0))
+
+	err := r.LoadConfig(strings.NewReader(`{
+		"defaults": {"waitInterval": "1h"},
+		"circuits": {"users": {"failThreshold": 2}, "orders": {"successThreshold": 2}}
+	}`))
+	require.ErrorIs(t, err, ErrInvalidConfig, "LoadConfig() - err = %v, wantErr = %v", err, ErrInvalidConfig)
+
+	var configErr *ConfigError
+	require.ErrorAs(t, err, &configErr, "LoadConfig() - err = %v, want ConfigError", err)
+	wantFields := []FieldError{{Field: "circuits.users.successThreshold", Reason: "must be greater than 0, got 0"}}
+	require.Equal(t, wantFields, configErr.Fields, "LoadConfig() - fields = %v, want = %v", configErr.Fields, wantFields)
+
+	require.Empty(t, r.Stats(), "LoadConfig() - circuits registered after error")
+	require.Empty(t, r.defaultOpts, "LoadConfig() - defaults replaced after error")
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "circuits.json")
+	err := os.WriteFile(path, []byte(`{"circuits": {"TestLoadConfigFile": {"failThreshold": 1, "waitInterval": "1h"}}}`), 0o600)
+	require.NoError(t, err, "WriteFile() - err = %v, want no error", err)
+
+	t.Cleanup(func() { _ = Remove("TestLoadConfigFile") })
+
+	err = LoadConfigFile(path)
+	require.NoError(t, err, "LoadConfigFile() - err = %v, want no error", err)
+
+	_, _ = Do[int]("TestLoadConfigFile", func() (int, error) { return 0, os.ErrNotExist })
+	_, err = Do[string]("TestLoadConfigFile", func() (string, error) { return "", nil })
+	require.ErrorIs(t, err, ErrCircuitOpen, "Do() - err = %v, wantErr = %v", err, ErrCircuitOpen)
+
+	err = LoadConfigFile(filepath.Join(t.TempDir(), "missing.json"))
+	require.ErrorIs(t, err, os.ErrNotExist, "LoadConfigFile() - err = %v, wantErr = %v", err, os.ErrNotExist)
+}
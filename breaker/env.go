@@ -0,0 +1,252 @@
+package breaker
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	_envPrefix  = "TRIPSWITCH_"
+	_envDefault = "DEFAULT"
+)
+
+// EnvError is the error reported for an environment variable that cannot be applied.
+type EnvError struct {
+	// Variable is the name of the environment variable.
+	Variable string
+
+	// Value is the value of the environment variable.
+	Value string
+
+	// Err is the reason the value cannot be applied.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *EnvError) Error() string {
+	return fmt.Sprintf("%s: invalid value %q: %s", e.Variable, e.Value, e.Err)
+}
+
+// Unwrap returns the reason the value cannot be applied.
+func (e *EnvError) Unwrap() error {
+	return e.Err
+}
+
+// EnvOverride represents an option overridden by an environment variable.
+type EnvOverride struct {
+	// Variable is the name of the environment variable.
+	Variable string
+
+	// Value is the value of the environment variable.
+	Value string
+}
+
+// EnvReport reports the options overridden by the environment variables.
+type EnvReport struct {
+	// Overrides are the environment variables applied.
+	Overrides []EnvOverride
+
+	// Errors are the environment variables that cannot be applied.
+	// The options they refer to keep the value provided by the code.
+	Errors []*EnvError
+}
+
+// Err returns an error describing all the environment variables that cannot be applied, if any.
+func (r EnvReport) Err() error {
+	switch len(r.Errors) {
+	case 0:
+		return nil
+	case 1:
+		return r.Errors[0]
+	}
+
+	msgs := make([]string, 0, len(r.Errors))
+	for _, err := range r.Errors {
+		msgs = append(msgs, err.Error())
+	}
+
+	return errors.New(strings.Join(msgs, "; "))
+}
+
+// envSetting represents a setting of a circuit that can be overridden by an environment variable.
+type envSetting struct {
+	suffix string
+	parse  func(value string) (Option, error)
+}
+
+// _envSettings are the settings that can be overridden.
+var _envSettings = []envSetting{
+	{"FAIL_THRESHOLD", envInt(1, func(cfg *config, v int) { cfg.failThreshold = int32(v) })},
+	{"FAILURE_RATE_THRESHOLD", envPercentage(func(cfg *config, v float64) { cfg.failureRateThreshold = v })},
+	{"HALF_OPEN_MAX_CALLS", envInt(0, func(cfg *config, v int) { cfg.halfOpenMaxCalls = int32(v) })},
+	{"IGNORE_CONTEXT_ERRORS", envBool(func(cfg *config, v bool) { cfg.ignoreContextErrors = v })},
+	{"LAZY_RESTORE", envBool(func(cfg *config, v bool) { cfg.lazyRestore = v })},
+	{"MAX_WAIT_INTERVAL", envDuration(false, func(cfg *config, v time.Duration) { cfg.maxWaitInterval = v })},
+	{"MINIMUM_REQUESTS", envInt(0, func(cfg *config, v int) { cfg.minimumRequests = v })},
+	{"PANIC_POLICY", envPanicPolicy},
+	{"SLOW_CALL_RATE", envPercentage(func(cfg *config, v float64) { cfg.slowCallRateThreshold = v })},
+	{"SLOW_CALL_THRESHOLD", envDuration(true, func(cfg *config, v time.Duration) { cfg.slowCallThreshold = v })},
+	{"SUCCESS_THRESHOLD", envInt(1, func(cfg *config, v int) { cfg.successThreshold = int32(v) })},
+	{"WAIT_INTERVAL", envDuration(true, func(cfg *config, v time.Duration) { cfg.waitInterval = v })},
+	{"WAIT_INTERVAL_JITTER", envFloat(0, 1, func(cfg *config, v float64) { cfg.waitJitter = v })},
+	{"WAIT_MULTIPLIER", envFloat(1, math.Inf(1), func(cfg *config, v float64) { cfg.waitMultiplier = v })},
+	{"WINDOW_SIZE", envInt(1, func(cfg *config, v int) { cfg.windowSize = v })},
+}
+
+// EnvOptions returns the options of a named circuit overridden by the TRIPSWITCH_<NAME>_<SETTING>
+// environment variables, such as TRIPSWITCH_USERS_FAIL_THRESHOLD, along with the report of the
+// applied variables. The name is converted to upper case and any character other than letters
+// and digits is replaced by an underscore.
+// The supported settings are FAIL_THRESHOLD, FAILURE_RATE_THRESHOLD, HALF_OPEN_MAX_CALLS,
+// IGNORE_CONTEXT_ERRORS, LAZY_RESTORE, MAX_WAIT_INTERVAL, MINIMUM_REQUESTS, PANIC_POLICY,
+// SLOW_CALL_RATE, SLOW_CALL_THRESHOLD, SUCCESS_THRESHOLD, WAIT_INTERVAL, WAIT_INTERVAL_JITTER,
+// WAIT_MULTIPLIER and WINDOW_SIZE. Durations are parsed by time.ParseDuration.
+func EnvOptions(name string) ([]Option, EnvReport) {
+	return envOptions(_envPrefix + envName(name) + "_")
+}
+
+// DefaultEnvOptions returns the default options overridden by the TRIPSWITCH_DEFAULT_<SETTING>
+// environment variables, such as TRIPSWITCH_DEFAULT_WAIT_INTERVAL, along with the report of the
+// applied variables. See EnvOptions for the supported settings.
+func DefaultEnvOptions() ([]Option, EnvReport) {
+	return envOptions(_envPrefix + _envDefault + "_")
+}
+
+// ConfigureWithEnv sets custom options for a named circuit breaker, overridden by the environment
+// variables returned by EnvOptions. The report lists the applied variables and the ones that cannot be applied,
+// which do not prevent the circuit breaker from being configured.
+func ConfigureWithEnv[T any](name string, opts ...Option) (EnvReport, error) {
+	envOpts, report := EnvOptions(name)
+
+	return report, Configure[T](name, append(opts[:len(opts):len(opts)], envOpts...)...)
+}
+
+// DefaultOptionsWithEnv overrides the default options, overridden in turn by the environment
+// variables returned by DefaultEnvOptions.
+func DefaultOptionsWithEnv(opts ...Option) EnvReport {
+	envOpts, report := DefaultEnvOptions()
+	DefaultOptions(append(opts[:len(opts):len(opts)], envOpts...)...)
+
+	return report
+}
+
+// envOptions returns the options overridden by the environment variables with the given prefix.
+func envOptions(prefix string) ([]Option, EnvReport) {
+	var (
+		opts   []Option
+		report EnvReport
+	)
+
+	for _, s := range _envSettings {
+		variable := prefix + s.suffix
+
+		value, ok := os.LookupEnv(variable)
+		if !ok {
+			continue
+		}
+
+		opt, err := s.parse(strings.TrimSpace(value))
+		if err != nil {
+			report.Errors = append(report.Errors, &EnvError{Variable: variable, Value: value, Err: err})
+			continue
+		}
+
+		opts = append(opts, opt)
+		report.Overrides = append(report.Overrides, EnvOverride{Variable: variable, Value: value})
+	}
+
+	return opts, report
+}
+
+// envName converts the name of a circuit to its environment variable form.
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return '_'
+		}
+
+		return unicode.ToUpper(r)
+	}, name)
+}
+
+func envInt(minValue int, apply func(cfg *config, v int)) func(string) (Option, error) {
+	return func(value string) (Option, error) {
+		v, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("must be an integer")
+		}
+
+		if v < minValue {
+			return nil, fmt.Errorf("must be at least %d", minValue)
+		}
+
+		return func(cfg *config) { apply(cfg, v) }, nil
+	}
+}
+
+func envFloat(minValue, maxValue float64, apply func(cfg *config, v float64)) func(string) (Option, error) {
+	return func(value string) (Option, error) {
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("must be a number")
+		}
+
+		if v < minValue {
+			return nil, fmt.Errorf("must be at least %v", minValue)
+		}
+
+		if v > maxValue {
+			return nil, fmt.Errorf("must be at most %v", maxValue)
+		}
+
+		return func(cfg *config) { apply(cfg, v) }, nil
+	}
+}
+
+func envPercentage(apply func(cfg *config, v float64)) func(string) (Option, error) {
+	return envFloat(0, 100, apply)
+}
+
+func envBool(apply func(cfg *config, v bool)) func(string) (Option, error) {
+	return func(value string) (Option, error) {
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("must be a boolean")
+		}
+
+		return func(cfg *config) { apply(cfg, v) }, nil
+	}
+}
+
+func envDuration(positive bool, apply func(cfg *config, v time.Duration)) func(string) (Option, error) {
+	return func(value string) (Option, error) {
+		v, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("must be a duration, such as \"30s\"")
+		}
+
+		switch {
+		case positive && v <= 0:
+			return nil, fmt.Errorf("must be greater than 0")
+		case v < 0:
+			return nil, fmt.Errorf("must not be negative")
+		}
+
+		return func(cfg *config) { apply(cfg, v) }, nil
+	}
+}
+
+func envPanicPolicy(value string) (Option, error) {
+	policy, ok := parsePanicPolicy(value)
+	if !ok {
+		return nil, fmt.Errorf("must be one of \"recover\", \"report\" or \"repanic\"")
+	}
+
+	return WithPanicPolicy(policy), nil
+}
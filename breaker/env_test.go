@@ -0,0 +1,138 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvOptions(t *testing.T) {
+	tests := []struct {
+		name          string
+		circuit       string
+		env           map[string]string
+		wantFn        func(t *testing.T, cfg config)
+		wantOverrides []EnvOverride
+		wantErrs      []string
+	}{
+		{
+			name:    "no variables",
+			circuit: "users",
+			wantFn: func(t *testing.T, cfg config) {
+				require.Equal(t, _defaultFailThreshold, cfg.failThreshold,
+					"EnvOptions() - failThreshold = %v, want = %v", cfg.failThreshold, _defaultFailThreshold)
+			},
+		},
+		{
+			name:    "overrides",
+			circuit: "user-service",
+			env: map[string]string{
+				"TRIPSWITCH_USER_SERVICE_FAIL_THRESHOLD": "7",
+				"TRIPSWITCH_USER_SERVICE_WAIT_INTERVAL":  "1m",
+				"TRIPSWITCH_USER_SERVICE_LAZY_RESTORE":   "true",
+				"TRIPSWITCH_USERS_FAIL_THRESHOLD":        "9",
+			},
+			wantFn: func(t *testing.T, cfg config) {
+				require.Equal(t, int32(7), cfg.failThreshold, "EnvOptions() - failThreshold = %v, want = %v", cfg.failThreshold, 7)
+				require.Equal(t, time.Minute, cfg.waitInterval, "EnvOptions() - waitInterval = %v, want = %v", cfg.waitInterval, time.Minute)
+				require.True(t, cfg.lazyRestore, "EnvOptions() - lazyRestore = %v, want = %v", cfg.lazyRestore, true)
+			},
+			wantOverrides: []EnvOverride{
+				{Variable: "TRIPSWITCH_USER_SERVICE_FAIL_THRESHOLD", Value: "7"},
+				{Variable: "TRIPSWITCH_USER_SERVICE_LAZY_RESTORE", Value: "true"},
+				{Variable: "TRIPSWITCH_USER_SERVICE_WAIT_INTERVAL", Value: "1m"},
+			},
+		},
+		{
+			name:    "invalid values",
+			circuit: "users",
+			env: map[string]string{
+				"TRIPSWITCH_USERS_FAIL_THRESHOLD":    "abc",
+				"TRIPSWITCH_USERS_SUCCESS_THRESHOLD": "0",
+				"TRIPSWITCH_USERS_WAIT_INTERVAL":     "10",
+				"TRIPSWITCH_USERS_PANIC_POLICY":      "report",
+			},
+			wantFn: func(t *testing.T, cfg config) {
+				require.Equal(t, _defaultFailThreshold, cfg.failThreshold,
+					"EnvOptions() - failThreshold = %v, want = %v", cfg.failThreshold, _defaultFailThreshold)
+				require.Equal(t, PanicReport, cfg.panicPolicy, "EnvOptions() - panicPolicy = %v, want = %v", cfg.panicPolicy, PanicReport)
+			},
+			wantOverrides: []EnvOverride{
+				{Variable: "TRIPSWITCH_USERS_PANIC_POLICY", Value: "report"},
+			},
+			wantErrs: []string{
+				`TRIPSWITCH_USERS_FAIL_THRESHOLD: invalid value "abc": must be an integer`,
+				`TRIPSWITCH_USERS_SUCCESS_THRESHOLD: invalid value "0": must be at least 1`,
+				`TRIPSWITCH_USERS_WAIT_INTERVAL: invalid value "10": must be a duration, such as "30s"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			opts, report := EnvOptions(tt.circuit)
+			tt.wantFn(t, newConfig(opts...))
+
+			require.ElementsMatch(t, tt.wantOverrides, report.Overrides,
+				"EnvOptions() - overrides = %v, want = %v", report.Overrides, tt.wantOverrides)
+
+			var gotErrs []string
+			for _, err := range report.Errors {
+				gotErrs = append(gotErrs, err.Error())
+			}
+			require.ElementsMatch(t, tt.wantErrs, gotErrs, "EnvOptions() - errors = %v, want = %v", gotErrs, tt.wantErrs)
+
+			if len(tt.wantErrs) == 0 {
+				require.NoError(t, report.Err(), "Err() - err = %v, want no error", report.Err())
+			} else {
+				require.Error(t, report.Err(), "Err() - err = %v, want error", report.Err())
+			}
+		})
+	}
+}
+
+func TestConfigureWithEnv(t *testing.T) {
+	name := "TestConfigureWithEnv"
+	t.Cleanup(func() { _ = Remove(name) })
+	t.Setenv("TRIPSWITCH_TESTCONFIGUREWITHENV_FAIL_THRESHOLD", "1")
+
+	report, err := ConfigureWithEnv[int](name, WithFailThreshold(5), WithWaitInterval(time.Hour))
+	require.NoError(t, err, "ConfigureWithEnv() - err = %v, want no error", err)
+	require.Len(t, report.Overrides, 1, "ConfigureWithEnv() - overrides = %v, want 1", report.Overrides)
+
+	c := _registry.Circuit(name)
+	require.Equal(t, int32(1), c.settings().failThreshold, "ConfigureWithEnv() - failThreshold = %v, want = %v", c.settings().failThreshold, 1)
+	require.Equal(t, time.Hour, c.settings().waitInterval, "ConfigureWithEnv() - waitInterval = %v, want = %v", c.settings().waitInterval, time.Hour)
+}
+
+func TestDefaultOptionsWithEnv(t *testing.T) {
+	t.Cleanup(func() { DefaultOptions() })
+	t.Setenv("TRIPSWITCH_DEFAULT_WAIT_INTERVAL", "5s")
+
+	report := DefaultOptionsWithEnv(WithWaitInterval(time.Hour), WithFailThreshold(4))
+	require.Len(t, report.Overrides, 1, "DefaultOptionsWithEnv() - overrides = %v, want 1", report.Overrides)
+
+	cb := NewCircuitBreaker[int]()
+	defer cb.Close()
+	require.Equal(t, 5*time.Second, cb.settings().waitInterval, "DefaultOptionsWithEnv() - waitInterval = %v, want = %v", cb.settings().waitInterval, 5*time.Second)
+	require.Equal(t, int32(4), cb.settings().failThreshold, "DefaultOptionsWithEnv() - failThreshold = %v, want = %v", cb.settings().failThreshold, 4)
+}
+
+func Test_envName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "users", want: "USERS"},
+		{name: "user-service.v2", want: "USER_SERVICE_V2"},
+		{name: "ütf", want: "_TF"},
+	}
+	for _, tt := range tests {
+		got := envName(tt.name)
+		require.Equal(t, tt.want, got, "envName() - got = %v, want = %v", got, tt.want)
+	}
+}
@@ -0,0 +1,235 @@
+package breaker
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const _defaultEventBuffer = 64
+
+// EventKind represents the kind of activity reported by an event.
+type EventKind int
+
+// Enumeration of event kinds.
+const (
+	EventSuccess EventKind = iota
+	EventFailure
+	EventIgnored
+	EventRejected
+	EventPanic
+	EventSlowCall
+	EventStateChange
+)
+
+// String implements the Stringer interface.
+func (k EventKind) String() string {
+	switch k {
+	case EventSuccess:
+		return "success"
+	case EventFailure:
+		return "failure"
+	case EventIgnored:
+		return "ignored"
+	case EventRejected:
+		return "rejected"
+	case EventPanic:
+		return "panic"
+	case EventSlowCall:
+		return "slow-call"
+	case EventStateChange:
+		return "state-change"
+	}
+
+	return "undefined"
+}
+
+// Event represents an activity of a circuit breaker.
+type Event struct {
+	// Circuit is the name of the circuit breaker, if any.
+	Circuit string
+
+	// Kind is the kind of activity.
+	Kind EventKind
+
+	// Time is the time the activity happened.
+	Time time.Time
+
+	// Duration is the duration of the execution, for the events reporting its outcome.
+	Duration time.Duration
+
+	// Err is the error returned by the execution or by the circuit breaker, if any.
+	Err error
+
+	// OldState is the state before the change, for the EventStateChange events.
+	OldState CircuitState
+
+	// NewState is the state after the change, for the EventStateChange events.
+	NewState CircuitState
+}
+
+// DropPolicy represents the behaviour of a subscription when its buffer is full.
+type DropPolicy int
+
+// Enumeration of drop policies.
+const (
+	// DropNewest discards the events published while the buffer is full.
+	DropNewest DropPolicy = iota
+
+	// DropOldest discards the oldest buffered event to make room for the new one.
+	DropOldest
+)
+
+// SubscribeOption represents a functional option applicable to a subscription.
+type SubscribeOption func(s *Subscription)
+
+// WithEventBuffer overrides the default size of the buffer of a subscription.
+// Sizes lower than 1 are raised to 1, as an unbuffered subscription would drop most of the events.
+func WithEventBuffer(size int) SubscribeOption {
+	return func(s *Subscription) {
+		if size < 1 {
+			size = 1
+		}
+		s.ch = make(chan Event, size)
+	}
+}
+
+// WithDropPolicy overrides the default drop policy of a subscription.
+func WithDropPolicy(policy DropPolicy) SubscribeOption {
+	return func(s *Subscription) {
+		s.dropPolicy = policy
+	}
+}
+
+// Subscription represents a subscriber of the events of a circuit breaker.
+// Events are delivered without blocking the circuit breaker: when the buffer is full,
+// events are discarded according to the drop policy.
+type Subscription struct {
+	ch         chan Event
+	dropPolicy DropPolicy
+	dropped    uint64
+	hub        *eventHub
+}
+
+// C returns the channel delivering the events.
+// The channel is closed when the subscription is cancelled or the circuit breaker is closed.
+func (s *Subscription) C() <-chan Event {
+	return s.ch
+}
+
+// Dropped returns the number of events discarded because the buffer was full.
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Unsubscribe cancels the subscription and closes its channel.
+// Cancelling a subscription more than once has no effect.
+func (s *Subscription) Unsubscribe() {
+	s.hub.unsubscribe(s)
+}
+
+// deliver sends an event to the subscriber without blocking.
+func (s *Subscription) deliver(e Event) {
+	select {
+	case s.ch <- e:
+		return
+	default:
+	}
+
+	if s.dropPolicy == DropOldest {
+		select {
+		case <-s.ch:
+			atomic.AddUint64(&s.dropped, 1)
+		default:
+		}
+
+		select {
+		case s.ch <- e:
+			return
+		default:
+		}
+	}
+
+	atomic.AddUint64(&s.dropped, 1)
+}
+
+// eventHub dispatches the events of a circuit breaker to its subscribers.
+type eventHub struct {
+	closed bool
+	count  int32
+	lock   sync.RWMutex
+	subs   map[*Subscription]struct{}
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{
+		subs: make(map[*Subscription]struct{}),
+	}
+}
+
+// active reports whether there is any subscriber, allowing to skip the creation of the events.
+func (h *eventHub) active() bool {
+	return atomic.LoadInt32(&h.count) > 0
+}
+
+// subscribe registers a new subscriber.
+// The channel of the subscription is closed immediately if the hub has been closed.
+func (h *eventHub) subscribe(opts ...SubscribeOption) *Subscription {
+	s := &Subscription{
+		ch:  make(chan Event, _defaultEventBuffer),
+		hub: h,
+	}
+	for _, apply := range opts {
+		apply(s)
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if h.closed {
+		close(s.ch)
+		return s
+	}
+
+	h.subs[s] = struct{}{}
+	atomic.AddInt32(&h.count, 1)
+
+	return s
+}
+
+// unsubscribe removes a subscriber and closes its channel.
+func (h *eventHub) unsubscribe(s *Subscription) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if _, exists := h.subs[s]; !exists {
+		return
+	}
+
+	delete(h.subs, s)
+	atomic.AddInt32(&h.count, -1)
+	close(s.ch)
+}
+
+// publish delivers an event to all the subscribers.
+func (h *eventHub) publish(e Event) {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	for s := range h.subs {
+		s.deliver(e)
+	}
+}
+
+// close removes all the subscribers and closes their channels.
+func (h *eventHub) close() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for s := range h.subs {
+		delete(h.subs, s)
+		close(s.ch)
+	}
+	atomic.StoreInt32(&h.count, 0)
+	h.closed = true
+}
@@ -0,0 +1,162 @@
+package breaker
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mgiaccone/tripswitch/clock"
+)
+
+func TestSubscription_deliver(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        []SubscribeOption
+		published   []EventKind
+		want        []EventKind
+		wantDropped uint64
+	}{
+		{
+			name:      "buffer not full",
+			opts:      []SubscribeOption{WithEventBuffer(3)},
+			published: []EventKind{EventSuccess, EventFailure},
+			want:      []EventKind{EventSuccess, EventFailure},
+		},
+		{
+			name:        "drop newest events",
+			opts:        []SubscribeOption{WithEventBuffer(2)},
+			published:   []EventKind{EventSuccess, EventFailure, EventRejected, EventPanic},
+			want:        []EventKind{EventSuccess, EventFailure},
+			wantDropped: 2,
+		},
+		{
+			name:        "drop oldest events",
+			opts:        []SubscribeOption{WithEventBuffer(2), WithDropPolicy(DropOldest)},
+			published:   []EventKind{EventSuccess, EventFailure, EventRejected, EventPanic},
+			want:        []EventKind{EventRejected, EventPanic},
+			wantDropped: 2,
+		},
+		{
+			name:        "zero buffer",
+			opts:        []SubscribeOption{WithEventBuffer(0)},
+			published:   []EventKind{EventSuccess, EventFailure},
+			want:        []EventKind{EventSuccess},
+			wantDropped: 1,
+		},
+		{
+			name:        "negative buffer",
+			opts:        []SubscribeOption{WithEventBuffer(-1)},
+			published:   []EventKind{EventSuccess, EventFailure},
+			want:        []EventKind{EventSuccess},
+			wantDropped: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			hub := newEventHub()
+			sub := hub.subscribe(tt.opts...)
+
+			for _, kind := range tt.published {
+				hub.publish(Event{Kind: kind})
+			}
+			sub.Unsubscribe()
+
+			var got []EventKind
+			for e := range sub.C() {
+				got = append(got, e.Kind)
+			}
+
+			require.Equal(t, tt.want, got, "deliver() - got = %v, want = %v", got, tt.want)
+			require.Equal(t, tt.wantDropped, sub.Dropped(), "Dropped() - got = %v, want = %v", sub.Dropped(), tt.wantDropped)
+		})
+	}
+}
+
+func TestEventHub_unsubscribe(t *testing.T) {
+	hub := newEventHub()
+	first := hub.subscribe()
+	second := hub.subscribe()
+	require.True(t, hub.active(), "active() - got = false, want = true")
+
+	first.Unsubscribe()
+	first.Unsubscribe()
+	hub.publish(Event{Kind: EventSuccess})
+
+	_, ok := <-first.C()
+	require.False(t, ok, "Unsubscribe() - channel not closed")
+	require.Len(t, second.C(), 1, "publish() - buffered = %v, want = %v", len(second.C()), 1)
+
+	hub.close()
+	require.False(t, hub.active(), "active() - got = true, want = false")
+
+	<-second.C()
+	_, ok = <-second.C()
+	require.False(t, ok, "close() - channel not closed")
+
+	late := hub.subscribe()
+	_, ok = <-late.C()
+	require.False(t, ok, "subscribe() - channel not closed after hub close")
+}
+
+func TestCircuitBreaker_Subscribe(t *testing.T) {
+	testErr := fmt.Errorf("test error")
+	fakeClock := clock.NewFake(time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC))
+
+	cb := NewCircuitBreaker[int](
+		WithClock(fakeClock),
+		WithFailThreshold(2),
+		WithLazyRestore(true),
+		WithName("sample"),
+		WithSlowCallThreshold(time.Second, 100),
+	)
+	sub := cb.Subscribe()
+
+	_, _ = cb.Do(func() (int, error) {
+		fakeClock.Advance(2 * time.Second)
+		return 1, nil
+	})
+	_, _ = cb.Do(func() (int, error) { panic("test panic") })
+	_, _ = cb.Do(func() (int, error) { return 0, testErr })
+	_, _ = cb.Do(func() (int, error) { return 1, nil })
+	cb.Close()
+
+	type kindErr struct {
+		kind EventKind
+		err  error
+	}
+
+	want := []kindErr{
+		{EventSuccess, nil},
+		{EventSlowCall, nil},
+		{EventPanic, ErrPanicRecovered},
+		{EventFailure, testErr},
+		{EventStateChange, nil},
+		{EventRejected, ErrCircuitOpen},
+	}
+
+	var got []kindErr
+	for e := range sub.C() {
+		require.Equal(t, "sample", e.Circuit, "Subscribe() - Circuit = %v, want = %v", e.Circuit, "sample")
+		require.Equal(t, fakeClock.Now(), e.Time, "Subscribe() - Time = %v, want = %v", e.Time, fakeClock.Now())
+
+		if e.Kind == EventStateChange {
+			require.Equal(t, CircuitClosed, e.OldState, "Subscribe() - OldState = %v, want = %v", e.OldState, CircuitClosed)
+			require.Equal(t, CircuitOpen, e.NewState, "Subscribe() - NewState = %v, want = %v", e.NewState, CircuitOpen)
+		}
+		// the typed errors are compared with their sentinel errors
+		err := e.Err
+		for _, sentinel := range []error{ErrPanicRecovered, ErrCircuitOpen} {
+			if errors.Is(err, sentinel) {
+				err = sentinel
+			}
+		}
+		got = append(got, kindErr{e.Kind, err})
+	}
+
+	require.Equal(t, want, got, "Subscribe() - got = %v, want = %v", got, want)
+}
@@ -0,0 +1,24 @@
+package breaker
+
+// FallbackFunc represents the function providing an alternative result when a protected execution
+// fails or is rejected by the circuit breaker. The error of the execution is passed to the function,
+// wrapped in a RejectedError when the protected function has not been called at all.
+type FallbackFunc[T any] func(err error) (T, error)
+
+// RejectedError is passed to the fallback function when an execution has been rejected by the
+// circuit breaker, either because the circuit is open, the maximum number of trial executions
+// has been reached or the circuit breaker has been closed.
+// It wraps the error explaining the reason of the rejection.
+type RejectedError struct {
+	Err error
+}
+
+// Error implements the error interface.
+func (e *RejectedError) Error() string {
+	return "rejected: " + e.Err.Error()
+}
+
+// Unwrap returns the error explaining the reason of the rejection.
+func (e *RejectedError) Unwrap() error {
+	return e.Err
+}
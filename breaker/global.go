@@ -1,19 +1,15 @@
 package breaker
 
 import (
+	"context"
 	"errors"
-	"reflect"
-	"strings"
-	"sync"
-	"unsafe"
 
 	"github.com/mgiaccone/tripswitch/internal/coreutil"
 )
 
 var (
-	_circuits     = make(map[string]*entry)
-	_circuitsLock sync.Mutex
-	_defaultOpts  []Option
+	_defaultOpts []Option
+	_registry    = NewRegistry()
 )
 
 var (
@@ -27,51 +23,37 @@ var (
 	// a circuit that already exists.
 	ErrDuplicateCircuit = errors.New("duplicate circuit")
 
-	// ErrTypeMismatch is returned when a circuit configured for a type
-	// is used with a different generic type.
+	// ErrTypeMismatch was returned when a circuit configured for a type was used with a different generic type.
+	//
+	// Deprecated: named circuits are shared by all the result types and this error is no longer returned.
 	ErrTypeMismatch = errors.New("circuit breaker type mismatch")
-)
 
-type entry struct {
-	typ reflect.Type
-	ptr unsafe.Pointer
-}
+	// ErrUnknownCircuit is returned when a named circuit does not exist.
+	ErrUnknownCircuit = errors.New("unknown circuit")
+)
 
 // Configure sets custom options for a named circuit breaker.
+// A ConfigError listing all the invalid settings is returned if the options are not valid.
 func Configure[T any](name string, opts ...Option) error {
 	return ConfigureWithRetrier[T](name, &nopRetrier[T]{}, opts...)
 }
 
 // ConfigureWithRetrier sets a retrier and custom options for a named circuit breaker.
 func ConfigureWithRetrier[T any](name string, retrier Retrier[T], opts ...Option) error {
-	if len(strings.TrimSpace(name)) == 0 {
-		return ErrRequiredName
-	}
-
 	if retrier == nil {
 		return ErrRequiredRetrier
 	}
 
-	_circuitsLock.Lock()
-	defer _circuitsLock.Unlock()
-
-	if _, exists := _circuits[name]; exists {
-		return ErrDuplicateCircuit
-	}
-
-	cb := NewCircuitBreakerWithRetrier[T](retrier, opts...)
-
-	_circuits[name] = &entry{
-		typ: reflect.TypeOf(cb),
-		ptr: unsafe.Pointer(reflect.ValueOf(cb).Pointer()),
-	}
-
-	return nil
+	return _registry.add(name, opts, func(opts []Option) *entry {
+		cb := NewCircuitBreakerWithRetrier[T](retrier, opts...)
+		return &entry{circuit: cb.Circuit, view: cb}
+	})
 }
 
 // DefaultOptions overrides the default options.
 func DefaultOptions(opts ...Option) {
-	_defaultOpts = opts
+	// the options are copied, so that appending to the defaults never writes to a shared array
+	_defaultOpts = append([]Option(nil), opts...)
 }
 
 // MustConfigure sets custom options for a named circuit breaker.
@@ -88,35 +70,59 @@ func MustWithRetrier[T any](name string, retrier Retrier[T], opts ...Option) {
 
 // Do wraps a function execution with a named circuit breaker.
 func Do[T any](name string, fn ProtectedFunc[T]) (res T, err error) {
-	cb, err := getOrCreateEntry[T](name)
-	if err != nil {
-		// nolint:gocritic
-		return *new(T), err
-	}
+	return getOrCreate[T](_registry, name).Do(fn)
+}
+
+// DoWithFallback wraps a function execution with a named circuit breaker, returning the result
+// of the fallback function when the execution fails or is rejected.
+// See CircuitBreaker.DoWithFallback for details.
+func DoWithFallback[T any](name string, fn ProtectedFunc[T], fallback FallbackFunc[T]) (res T, err error) {
+	return getOrCreate[T](_registry, name).DoWithFallback(fn, fallback)
+}
 
-	return cb.Do(fn)
+// Allow checks whether a named circuit breaker allows an execution, for the code that cannot be wrapped
+// in a ProtectedFunc. See CircuitBreaker.Allow for details.
+func Allow[T any](name string) (func(err error), error) {
+	return getOrCreate[T](_registry, name).Allow()
 }
 
-func getOrCreateEntry[T any](name string) (*CircuitBreaker[T], error) {
-	_circuitsLock.Lock()
-	defer _circuitsLock.Unlock()
+// ForceOpen sets the state of a named circuit breaker to CircuitForcedOpen.
+func ForceOpen(name string) error {
+	return _registry.ForceOpen(name)
+}
 
-	v, exists := _circuits[name]
-	if exists {
-		vTyp := reflect.TypeOf((*CircuitBreaker[T])(v.ptr))
-		if vTyp.String() != v.typ.String() {
-			return nil, ErrTypeMismatch
-		}
-	}
+// ForceClosed sets the state of a named circuit breaker to CircuitForcedClosed.
+func ForceClosed(name string) error {
+	return _registry.ForceClosed(name)
+}
 
-	cb := (*CircuitBreaker[T])(v.ptr)
-	if cb == nil {
-		cb = NewCircuitBreaker[T]()
-		_circuits[name] = &entry{
-			typ: reflect.TypeOf(cb),
-			ptr: unsafe.Pointer(reflect.ValueOf(cb).Pointer()),
-		}
-	}
+// Disable sets the state of a named circuit breaker to CircuitDisabled.
+func Disable(name string) error {
+	return _registry.Disable(name)
+}
+
+// Reset sets the state of a named circuit breaker to CircuitClosed, clearing all its counters.
+func Reset(name string) error {
+	return _registry.Reset(name)
+}
+
+// Reconfigure replaces the options of a named circuit breaker while it is running,
+// preserving its state and counters.
+func Reconfigure(name string, opts ...Option) error {
+	return _registry.Reconfigure(name, opts...)
+}
+
+// AllStats returns a snapshot of the runtime statistics of all the named circuit breakers.
+func AllStats() map[string]Stats {
+	return _registry.Stats()
+}
+
+// Remove closes a named circuit breaker and removes it from the configured circuits.
+func Remove(name string) error {
+	return _registry.Remove(name)
+}
 
-	return cb, nil
+// DoContext wraps a context aware function execution with a named circuit breaker.
+func DoContext[T any](ctx context.Context, name string, fn ProtectedContextFunc[T]) (res T, err error) {
+	return getOrCreate[T](_registry, name).DoContext(ctx, fn)
 }
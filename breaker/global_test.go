@@ -1 +1,222 @@
 package breaker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoContext(t *testing.T) {
+	name := "TestDoContext"
+	t.Cleanup(func() { _ = Remove(name) })
+
+	got, err := DoContext[int](context.Background(), name, func(ctx context.Context) (int, error) {
+		return 1, nil
+	})
+	require.NoError(t, err, "DoContext() - err = %v, want no error", err)
+	require.Equal(t, 1, got, "DoContext() - got = %v, want = %v", got, 1)
+
+	gotStr, err := DoContext[string](context.Background(), name, func(ctx context.Context) (string, error) {
+		return "a", nil
+	})
+	require.NoError(t, err, "DoContext() - err = %v, want no error", err)
+	require.Equal(t, "a", gotStr, "DoContext() - got = %v, want = %v", gotStr, "a")
+}
+
+func TestDo_sharedAcrossTypes(t *testing.T) {
+	name := "TestDo_sharedAcrossTypes"
+	t.Cleanup(func() { _ = Remove(name) })
+	MustConfigure[int](name, WithFailThreshold(2), WithWaitInterval(time.Hour))
+
+	_, err := Do[int](name, func() (int, error) { return 0, fmt.Errorf("test error") })
+	require.Error(t, err, "Do() - err = %v, want error", err)
+
+	_, err = Do[string](name, func() (string, error) { return "", fmt.Errorf("test error") })
+	require.Error(t, err, "Do() - err = %v, want error", err)
+
+	_, err = Do[int](name, func() (int, error) { return 1, nil })
+	require.ErrorIs(t, err, ErrCircuitOpen, "Do() - err = %v, wantErr = %v", err, ErrCircuitOpen)
+
+	got := AllStats()[name]
+	require.Equal(t, uint64(2), got.Failures, "AllStats() - Failures = %v, want = %v", got.Failures, 2)
+	require.Equal(t, uint64(1), got.Rejections, "AllStats() - Rejections = %v, want = %v", got.Rejections, 1)
+}
+
+func TestForceOpen(t *testing.T) {
+	name := "TestForceOpen"
+	t.Cleanup(func() { _ = Remove(name) })
+	MustConfigure[int](name)
+
+	err := ForceOpen(name)
+	require.NoError(t, err, "ForceOpen() - err = %v, want no error", err)
+
+	_, err = Do[int](name, func() (int, error) { return 1, nil })
+	require.ErrorIs(t, err, ErrCircuitOpen, "Do() - err = %v, wantErr = %v", err, ErrCircuitOpen)
+
+	err = Reset(name)
+	require.NoError(t, err, "Reset() - err = %v, want no error", err)
+
+	_, err = Do[int](name, func() (int, error) { return 1, nil })
+	require.NoError(t, err, "Do() - err = %v, want no error", err)
+}
+
+func TestConfigure_invalid(t *testing.T) {
+	name := "TestConfigure_invalid"
+
+	err := Configure[int](name, WithFailThreshold(0), WithSuccessThreshold(-1), WithWaitInterval(0))
+	require.ErrorIs(t, err, ErrInvalidConfig, "Configure() - err = %v, wantErr = %v", err, ErrInvalidConfig)
+
+	var configErr *ConfigError
+	require.ErrorAs(t, err, &configErr, "Configure() - err = %v, want ConfigError", err)
+	require.Equal(t, name, configErr.Name, "Configure() - Name = %v, want = %v", configErr.Name, name)
+	require.Len(t, configErr.Fields, 3, "Configure() - fields = %v, want 3", configErr.Fields)
+
+	err = Remove(name)
+	require.ErrorIs(t, err, ErrUnknownCircuit, "Remove() - err = %v, wantErr = %v", err, ErrUnknownCircuit)
+
+	require.Panics(t, func() { MustConfigure[int](name, WithFailThreshold(0)) }, "MustConfigure() - want panic")
+}
+
+func TestReconfigure(t *testing.T) {
+	name := "TestReconfigure"
+	t.Cleanup(func() { _ = Remove(name) })
+	MustConfigure[int](name, WithFailThreshold(3), WithWaitInterval(time.Hour))
+
+	testErr := fmt.Errorf("test error")
+	_, _ = Do[int](name, func() (int, error) { return 0, testErr })
+
+	err := Reconfigure(name, WithFailThreshold(2), WithWaitInterval(time.Hour))
+	require.NoError(t, err, "Reconfigure() - err = %v, want no error", err)
+
+	_, _ = Do[int](name, func() (int, error) { return 0, testErr })
+	_, err = Do[int](name, func() (int, error) { return 1, nil })
+	require.ErrorIs(t, err, ErrCircuitOpen, "Do() - err = %v, wantErr = %v", err, ErrCircuitOpen)
+
+	err = Reconfigure(name, WithWaitInterval(0))
+	require.ErrorIs(t, err, ErrInvalidConfig, "Reconfigure() - err = %v, wantErr = %v", err, ErrInvalidConfig)
+
+	err = Reconfigure("TestReconfigure_unknown")
+	require.ErrorIs(t, err, ErrUnknownCircuit, "Reconfigure() - err = %v, wantErr = %v", err, ErrUnknownCircuit)
+}
+
+func TestManualOverride_unknownCircuit(t *testing.T) {
+	name := "TestManualOverride_unknownCircuit"
+
+	tests := []struct {
+		name       string
+		overrideFn func(name string) error
+	}{
+		{name: "force open", overrideFn: ForceOpen},
+		{name: "force closed", overrideFn: ForceClosed},
+		{name: "disable", overrideFn: Disable},
+		{name: "reset", overrideFn: Reset},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.overrideFn(name)
+			require.ErrorIs(t, err, ErrUnknownCircuit, "%s() - err = %v, wantErr = %v", tt.name, err, ErrUnknownCircuit)
+		})
+	}
+}
+
+func TestRemove(t *testing.T) {
+	name := "TestRemove"
+	MustConfigure[int](name)
+
+	cb := getOrCreate[int](_registry, name)
+
+	err := Remove(name)
+	require.NoError(t, err, "Remove() - err = %v, want no error", err)
+
+	_, err = cb.Do(func() (int, error) { return 1, nil })
+	require.ErrorIs(t, err, ErrBreakerClosed, "Do() - err = %v, wantErr = %v", err, ErrBreakerClosed)
+
+	err = Remove(name)
+	require.ErrorIs(t, err, ErrUnknownCircuit, "Remove() - err = %v, wantErr = %v", err, ErrUnknownCircuit)
+
+	_, err = Do[string](name, func() (string, error) { return "", nil })
+	require.NoError(t, err, "Do() - err = %v, want no error", err)
+
+	err = Remove(name)
+	require.NoError(t, err, "Remove() - err = %v, want no error", err)
+}
+
+func TestAllStats(t *testing.T) {
+	name := "TestAllStats"
+	t.Cleanup(func() { _ = Remove(name) })
+
+	_, err := Do[int](name, func() (int, error) { return 1, nil })
+	require.NoError(t, err, "Do() - err = %v, want no error", err)
+
+	stats := AllStats()
+	got, exists := stats[name]
+	require.True(t, exists, "AllStats() - circuit %s not found", name)
+	require.Equal(t, uint64(1), got.Successes, "AllStats() - Successes = %v, want = %v", got.Successes, 1)
+}
+
+func TestAllow(t *testing.T) {
+	name := "TestAllow"
+	t.Cleanup(func() { _ = Remove(name) })
+
+	done, err := Allow[int](name)
+	require.NoError(t, err, "Allow() - err = %v, want no error", err)
+	done(nil)
+
+	done, err = Allow[string](name)
+	require.NoError(t, err, "Allow() - err = %v, want no error", err)
+	done(nil)
+
+	got := AllStats()[name]
+	require.Equal(t, uint64(2), got.Successes, "AllStats() - Successes = %v, want = %v", got.Successes, 2)
+}
+
+func TestDoWithFallback(t *testing.T) {
+	name := "TestDoWithFallback"
+	t.Cleanup(func() { _ = Remove(name) })
+
+	res, err := DoWithFallback(name,
+		func() (int, error) { return 0, fmt.Errorf("test error") },
+		func(err error) (int, error) { return 1, nil },
+	)
+	require.NoError(t, err, "DoWithFallback() - err = %v, want no error", err)
+	require.Equal(t, 1, res, "DoWithFallback() - res = %v, want = %v", res, 1)
+
+	resStr, err := DoWithFallback(name,
+		func() (string, error) { return "", fmt.Errorf("test error") },
+		func(err error) (string, error) { return "a", nil },
+	)
+	require.NoError(t, err, "DoWithFallback() - err = %v, want no error", err)
+	require.Equal(t, "a", resStr, "DoWithFallback() - res = %v, want = %v", resStr, "a")
+}
+
+func TestDefaultOptions_concurrent(t *testing.T) {
+	t.Cleanup(func() { DefaultOptions() })
+
+	// the spare capacity of the defaults must not be shared by the circuits created concurrently
+	opts := make([]Option, 0, 8)
+	opts = append(opts, WithSuccessThreshold(2))
+	DefaultOptions(opts...)
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 8; i++ {
+		wg.Add(1)
+		go func(want int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				cb := NewCircuit(WithFailThreshold(want), WithLazyRestore(true))
+				got := cb.settings().failThreshold
+				if got != int32(want) {
+					t.Errorf("NewCircuit() - failThreshold = %v, want = %v", got, want)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,245 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mgiaccone/tripswitch/clock"
+)
+
+// ErrGroupFull is returned when a group cannot create the circuit breaker for a new key
+// because the maximum number of keys has been reached.
+var ErrGroupFull = errors.New("circuit breaker group full")
+
+// GroupOption represents a functional option applicable to a group of circuit breakers.
+type GroupOption func(cfg *groupConfig)
+
+type groupConfig struct {
+	idleTTL time.Duration
+	maxKeys int
+	opts    []Option
+}
+
+// WithCircuitOptions sets the options shared by all the circuit breakers of a group.
+// The name of each circuit breaker is set to the string representation of its key.
+func WithCircuitOptions(opts ...Option) GroupOption {
+	return func(cfg *groupConfig) {
+		cfg.opts = append(cfg.opts, opts...)
+	}
+}
+
+// WithIdleTTL enables the eviction of the circuit breakers of a group that have not been used for the given time.
+// Only the circuit breakers in the CircuitClosed or CircuitDisabled state are evicted, so that an idle key
+// does not lose an open circuit and hammer the failing dependency again once it is used.
+// By default, the circuit breakers are only evicted when they are closed.
+func WithIdleTTL(ttl time.Duration) GroupOption {
+	return func(cfg *groupConfig) {
+		cfg.idleTTL = ttl
+	}
+}
+
+// WithMaxKeys bounds the number of circuit breakers of a group.
+// By default, the number of circuit breakers is unbounded.
+func WithMaxKeys(n int) GroupOption {
+	return func(cfg *groupConfig) {
+		cfg.maxKeys = n
+	}
+}
+
+// Group is a set of circuit breakers sharing the same options, lazily created for each key.
+// It is useful to guard the calls per host, per tenant or per shard.
+// The circuit breakers that have been closed, or that have not been used for the idle TTL while in the
+// CircuitClosed or CircuitDisabled state, are evicted when a new key is added or the stats are collected.
+type Group[K comparable, T any] struct {
+	clock   clock.Clock
+	closed  bool
+	idleTTL time.Duration
+	lock    sync.Mutex
+	maxKeys int
+	members map[K]*groupMember[T]
+	opts    []Option
+}
+
+// groupMember is a circuit breaker of a group, along with its usage.
+type groupMember[T any] struct {
+	cb       *CircuitBreaker[T]
+	inflight int32
+	lastUsed int64
+}
+
+// NewGroup creates a new group of circuit breakers.
+func NewGroup[K comparable, T any](opts ...GroupOption) *Group[K, T] {
+	var cfg groupConfig
+	for _, apply := range opts {
+		apply(&cfg)
+	}
+
+	cfgOpts := _defaultOpts
+	cfgOpts = append(cfgOpts, cfg.opts...)
+
+	return &Group[K, T]{
+		clock:   newConfig(cfgOpts...).clock,
+		idleTTL: cfg.idleTTL,
+		maxKeys: cfg.maxKeys,
+		members: make(map[K]*groupMember[T]),
+		opts:    cfg.opts,
+	}
+}
+
+// Do wraps a function execution with the circuit breaker of a key.
+func (g *Group[K, T]) Do(key K, fn ProtectedFunc[T]) (T, error) {
+	m, err := g.acquire(key)
+	if err != nil {
+		// nolint:gocritic
+		return *new(T), err
+	}
+	defer g.release(m)
+
+	return m.cb.Do(fn)
+}
+
+// DoContext wraps a context aware function execution with the circuit breaker of a key.
+func (g *Group[K, T]) DoContext(ctx context.Context, key K, fn ProtectedContextFunc[T]) (T, error) {
+	m, err := g.acquire(key)
+	if err != nil {
+		// nolint:gocritic
+		return *new(T), err
+	}
+	defer g.release(m)
+
+	return m.cb.DoContext(ctx, fn)
+}
+
+// State returns the current state of the circuit breaker of a key.
+// A key without a circuit breaker is reported in the CircuitClosed state.
+func (g *Group[K, T]) State(key K) CircuitState {
+	g.lock.Lock()
+	m, exists := g.members[key]
+	g.lock.Unlock()
+
+	if !exists {
+		return CircuitClosed
+	}
+
+	return m.cb.State()
+}
+
+// Len returns the number of circuit breakers of the group.
+func (g *Group[K, T]) Len() int {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	return len(g.members)
+}
+
+// Stats returns a snapshot of the runtime statistics of the circuit breakers of the group, by key.
+func (g *Group[K, T]) Stats() map[K]Stats {
+	g.lock.Lock()
+	g.evict()
+	members := make(map[K]*groupMember[T], len(g.members))
+	for key, m := range g.members {
+		members[key] = m
+	}
+	g.lock.Unlock()
+
+	stats := make(map[K]Stats, len(members))
+	for key, m := range members {
+		stats[key] = m.cb.Stats()
+	}
+
+	return stats
+}
+
+// Remove closes the circuit breaker of a key and removes it from the group.
+// It reports whether the key had a circuit breaker.
+func (g *Group[K, T]) Remove(key K) bool {
+	g.lock.Lock()
+	m, exists := g.members[key]
+	delete(g.members, key)
+	g.lock.Unlock()
+
+	if exists {
+		m.cb.Close()
+	}
+
+	return exists
+}
+
+// Close closes all the circuit breakers of the group.
+// All the subsequent executions fail immediately with ErrBreakerClosed.
+func (g *Group[K, T]) Close() {
+	g.lock.Lock()
+	members := g.members
+	g.closed = true
+	g.members = make(map[K]*groupMember[T])
+	g.lock.Unlock()
+
+	for _, m := range members {
+		m.cb.Close()
+	}
+}
+
+// acquire returns the circuit breaker of a key, creating it if needed, and marks it as in use.
+func (g *Group[K, T]) acquire(key K) (*groupMember[T], error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	if g.closed {
+		return nil, ErrBreakerClosed
+	}
+
+	m, exists := g.members[key]
+	if !exists || atomic.LoadInt32(&m.cb.closed) == 1 {
+		g.evict()
+
+		if g.maxKeys > 0 && len(g.members) >= g.maxKeys {
+			return nil, ErrGroupFull
+		}
+
+		opts := append(g.opts[:len(g.opts):len(g.opts)], WithName(fmt.Sprint(key)))
+		m = &groupMember[T]{cb: NewCircuitBreaker[T](opts...)}
+		g.members[key] = m
+	}
+
+	atomic.AddInt32(&m.inflight, 1)
+	atomic.StoreInt64(&m.lastUsed, g.clock.Now().UnixNano())
+
+	return m, nil
+}
+
+// release marks the end of an execution of a circuit breaker acquired from the group.
+func (g *Group[K, T]) release(m *groupMember[T]) {
+	atomic.StoreInt64(&m.lastUsed, g.clock.Now().UnixNano())
+	atomic.AddInt32(&m.inflight, -1)
+}
+
+// evict removes the circuit breakers that have been closed, or that have not been used for the idle TTL,
+// have no execution in progress and are either in the CircuitClosed or CircuitDisabled state.
+// It must be called with the lock held.
+func (g *Group[K, T]) evict() {
+	now := g.clock.Now()
+
+	for key, m := range g.members {
+		if atomic.LoadInt32(&m.cb.closed) == 1 {
+			delete(g.members, key)
+			continue
+		}
+
+		if g.idleTTL <= 0 || atomic.LoadInt32(&m.inflight) > 0 {
+			continue
+		}
+
+		if state := CircuitState(atomic.LoadInt32((*int32)(&m.cb.state))); state != CircuitClosed && state != CircuitDisabled {
+			continue
+		}
+
+		if now.Sub(time.Unix(0, atomic.LoadInt64(&m.lastUsed))) >= g.idleTTL {
+			delete(g.members, key)
+			m.cb.Close()
+		}
+	}
+}
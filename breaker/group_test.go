@@ -0,0 +1,168 @@
+package breaker
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mgiaccone/tripswitch/clock"
+)
+
+func TestGroup_Do(t *testing.T) {
+	testErr := fmt.Errorf("test error")
+
+	g := NewGroup[string, int](WithCircuitOptions(WithFailThreshold(1), WithWaitInterval(time.Hour)))
+	defer g.Close()
+
+	_, err := g.Do("a", func() (int, error) { return 0, testErr })
+	require.ErrorIs(t, err, testErr, "Do() - err = %v, wantErr = %v", err, testErr)
+
+	_, err = g.Do("a", func() (int, error) { return 1, nil })
+	require.ErrorIs(t, err, ErrCircuitOpen, "Do() - err = %v, wantErr = %v", err, ErrCircuitOpen)
+
+	var openErr *OpenCircuitError
+	require.ErrorAs(t, err, &openErr, "Do() - err = %v, want OpenCircuitError", err)
+	require.Equal(t, "a", openErr.Name, "Do() - Name = %v, want = %v", openErr.Name, "a")
+
+	got, err := g.Do("b", func() (int, error) { return 1, nil })
+	require.NoError(t, err, "Do() - err = %v, want no error", err)
+	require.Equal(t, 1, got, "Do() - got = %v, want = %v", got, 1)
+
+	require.Equal(t, CircuitOpen, g.State("a"), "State() - got = %v, want = %v", g.State("a"), CircuitOpen)
+	require.Equal(t, CircuitClosed, g.State("b"), "State() - got = %v, want = %v", g.State("b"), CircuitClosed)
+	require.Equal(t, 2, g.Len(), "Len() - got = %v, want = %v", g.Len(), 2)
+
+	stats := g.Stats()
+	require.Equal(t, uint64(1), stats["a"].Failures, "Stats() - Failures = %v, want = %v", stats["a"].Failures, 1)
+	require.Equal(t, uint64(1), stats["a"].Rejections, "Stats() - Rejections = %v, want = %v", stats["a"].Rejections, 1)
+	require.Equal(t, uint64(1), stats["b"].Successes, "Stats() - Successes = %v, want = %v", stats["b"].Successes, 1)
+}
+
+func TestGroup_maxKeys(t *testing.T) {
+	fakeClock := clock.NewFake(time.Unix(1000, 0))
+
+	tests := []struct {
+		name    string
+		opts    []GroupOption
+		advance time.Duration
+		wantErr error
+		wantLen int
+	}{
+		{
+			name:    "unbounded",
+			opts:    nil,
+			wantLen: 3,
+		},
+		{
+			name:    "full",
+			opts:    []GroupOption{WithMaxKeys(2)},
+			wantErr: ErrGroupFull,
+			wantLen: 2,
+		},
+		{
+			name:    "full before idle ttl",
+			opts:    []GroupOption{WithMaxKeys(2), WithIdleTTL(time.Minute)},
+			advance: 30 * time.Second,
+			wantErr: ErrGroupFull,
+			wantLen: 2,
+		},
+		{
+			name:    "idle keys evicted",
+			opts:    []GroupOption{WithMaxKeys(2), WithIdleTTL(time.Minute)},
+			advance: time.Minute,
+			wantLen: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append(tt.opts, WithCircuitOptions(WithClock(fakeClock), WithLazyRestore(true)))
+			g := NewGroup[int, int](opts...)
+			defer g.Close()
+
+			fn := func() (int, error) { return 1, nil }
+			_, _ = g.Do(1, fn)
+			_, _ = g.Do(2, fn)
+			fakeClock.Advance(tt.advance)
+
+			_, err := g.Do(3, fn)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr, "Do() - err = %v, wantErr = %v", err, tt.wantErr)
+			} else {
+				require.NoError(t, err, "Do() - err = %v, want no error", err)
+			}
+			require.Equal(t, tt.wantLen, g.Len(), "Len() - got = %v, want = %v", g.Len(), tt.wantLen)
+		})
+	}
+}
+
+func TestGroup_evict(t *testing.T) {
+	fakeClock := clock.NewFake(time.Unix(1000, 0))
+
+	g := NewGroup[string, int](WithIdleTTL(time.Minute), WithCircuitOptions(
+		WithClock(fakeClock),
+		WithFailThreshold(1),
+		WithLazyRestore(true),
+		WithWaitInterval(time.Hour),
+	))
+	defer g.Close()
+
+	fn := func() (int, error) { return 1, nil }
+	_, _ = g.Do("idle", fn)
+	_, _ = g.Do("closed", fn)
+	_, _ = g.Do("open", func() (int, error) { return 0, fmt.Errorf("test error") })
+	_, _ = g.Do("disabled", fn)
+	g.members["disabled"].cb.Disable()
+
+	// an execution in progress prevents the eviction
+	inflightCh := make(chan struct{})
+	doneCh := make(chan struct{})
+	go func() {
+		_, _ = g.Do("inflight", func() (int, error) {
+			close(inflightCh)
+			<-doneCh
+			return 1, nil
+		})
+	}()
+	<-inflightCh
+
+	g.members["closed"].cb.Close()
+	fakeClock.Advance(30 * time.Second)
+	_, _ = g.Do("recent", fn)
+	fakeClock.Advance(30 * time.Second)
+
+	stats := g.Stats()
+	close(doneCh)
+
+	_, gotIdle := stats["idle"]
+	_, gotClosed := stats["closed"]
+	_, gotInflight := stats["inflight"]
+	_, gotRecent := stats["recent"]
+	_, gotOpen := stats["open"]
+	_, gotDisabled := stats["disabled"]
+	require.False(t, gotIdle, "Stats() - idle key not evicted")
+	require.False(t, gotClosed, "Stats() - closed key not evicted")
+	require.False(t, gotDisabled, "Stats() - disabled key not evicted")
+	require.True(t, gotOpen, "Stats() - open key evicted")
+	require.True(t, gotInflight, "Stats() - inflight key evicted")
+	require.True(t, gotRecent, "Stats() - recent key evicted")
+}
+
+func TestGroup_Remove(t *testing.T) {
+	g := NewGroup[string, int]()
+
+	_, _ = g.Do("a", func() (int, error) { return 1, nil })
+	cb := g.members["a"].cb
+
+	require.True(t, g.Remove("a"), "Remove() - got = false, want = true")
+	require.False(t, g.Remove("a"), "Remove() - got = true, want = false")
+
+	_, err := cb.Do(func() (int, error) { return 1, nil })
+	require.ErrorIs(t, err, ErrBreakerClosed, "Do() - err = %v, wantErr = %v", err, ErrBreakerClosed)
+
+	g.Close()
+
+	_, err = g.Do("a", func() (int, error) { return 1, nil })
+	require.ErrorIs(t, err, ErrBreakerClosed, "Do() - err = %v, wantErr = %v", err, ErrBreakerClosed)
+}
@@ -0,0 +1,64 @@
+package breaker
+
+import (
+	"sync/atomic"
+
+	"github.com/mgiaccone/tripswitch/internal/coreutil"
+)
+
+const _defaultStateChangeQueueSize = 16
+
+// stateChangeListener dispatches the state changes to a StateChangeFunc asynchronously,
+// isolating the circuit breaker and the other listeners from slow or panicking functions.
+// The delivery goroutine is only running while there are state changes to deliver.
+type stateChangeListener struct {
+	dropped uint64
+	fn      StateChangeFunc
+	queue   chan stateChangeEvent
+	running int32
+}
+
+func newStateChangeListener(fn StateChangeFunc, size int) *stateChangeListener {
+	return &stateChangeListener{
+		fn:    fn,
+		queue: make(chan stateChangeEvent, size),
+	}
+}
+
+// enqueue adds a state change to the queue without blocking, discarding it if the queue is full.
+func (l *stateChangeListener) enqueue(e stateChangeEvent) {
+	select {
+	case l.queue <- e:
+	default:
+		atomic.AddUint64(&l.dropped, 1)
+		return
+	}
+
+	if atomic.CompareAndSwapInt32(&l.running, 0, 1) {
+		go l.run()
+	}
+}
+
+// run delivers the queued state changes until the queue is empty.
+func (l *stateChangeListener) run() {
+	for {
+		select {
+		case e := <-l.queue:
+			l.invoke(e)
+		default:
+			atomic.StoreInt32(&l.running, 0)
+
+			// a state change could have been queued after the queue was found empty
+			if len(l.queue) == 0 || !atomic.CompareAndSwapInt32(&l.running, 0, 1) {
+				return
+			}
+		}
+	}
+}
+
+// invoke calls the listener function, recovering from any panic.
+func (l *stateChangeListener) invoke(e stateChangeEvent) {
+	defer coreutil.RecoverPanic(nil)
+
+	l.fn(e.oldState, e.newState)
+}
@@ -2,26 +2,48 @@ package breaker
 
 import (
 	"time"
+
+	"github.com/mgiaccone/tripswitch/clock"
 )
 
 // Option represents a functional option applicable to a circuit breaker.
 type Option func(cfg *config)
 
 type config struct {
-	failThreshold    int32
-	stateChangeFunc  StateChangeFunc
-	successThreshold int32
-	waitInterval     time.Duration
+	bucketWidth           time.Duration
+	clock                 clock.Clock
+	failThreshold         int32
+	failurePredicate      any
+	failureRateThreshold  float64
+	fallback              any
+	halfOpenMaxCalls      int32
+	ignoreContextErrors   bool
+	ignoredErrors         []error
+	lazyRestore           bool
+	maxWaitInterval       time.Duration
+	minimumRequests       int
+	name                  string
+	panicPolicy           PanicPolicy
+	slowCallRateThreshold float64
+	slowCallThreshold     time.Duration
+	stateChangeFunc       StateChangeFunc
+	stateChangeFuncs      []StateChangeFunc
+	stateChangeQueueSize  int
+	successThreshold      int32
+	waitInterval          time.Duration
+	waitJitter            float64
+	waitMultiplier        float64
+	windowSize            int
 }
 
 func newConfig(opts ...Option) config {
 	cfg := config{
-		stateChangeFunc: func(oldState, newState CircuitState) {
-			// nop by default
-		},
-		failThreshold:    _defaultFailThreshold,
-		successThreshold: _defaultSuccessThreshold,
-		waitInterval:     _defaultWaitInterval,
+		clock:                clock.New(),
+		failThreshold:        _defaultFailThreshold,
+		stateChangeQueueSize: _defaultStateChangeQueueSize,
+		successThreshold:     _defaultSuccessThreshold,
+		waitInterval:         _defaultWaitInterval,
+		windowSize:           _defaultWindowSize,
 	}
 	cfg.applyOpts(opts...)
 
@@ -34,6 +56,14 @@ func (c *config) applyOpts(opts ...Option) {
 	}
 }
 
+// WithClock overrides the source of time used by the circuit breaker.
+// It is mostly useful to drive the circuit breaker transitions deterministically in tests.
+func WithClock(c clock.Clock) Option {
+	return func(cfg *config) {
+		cfg.clock = c
+	}
+}
+
 // WithFailThreshold overrides the default value for the number of failes
 // executions required to trip the circuit breaker to its CircuitOpen state.
 func WithFailThreshold(threshold int) Option {
@@ -42,14 +72,160 @@ func WithFailThreshold(threshold int) Option {
 	}
 }
 
-// WithStateChangeFunc attaches a function that will receive notifications
-// of circuit breaker state changes.
+// WithFailurePredicate overrides the default classification of the executions, where every error
+// is considered a failure. The predicate reports whether the result and the error returned by the
+// protected function must be counted as a failure, allowing to ignore errors that are not related
+// to the health of the dependency or to count specific results as failures.
+// The predicate is ignored by circuit breakers with a different result type.
+func WithFailurePredicate[T any](fn func(res T, err error) bool) Option {
+	return func(cfg *config) {
+		cfg.failurePredicate = fn
+	}
+}
+
+// WithFallback sets the fallback function used by Do and DoContext when an execution fails or is
+// rejected by the circuit breaker, returning its result instead of the error.
+// The fallback is ignored by circuit breakers with a different result type.
+func WithFallback[T any](fn FallbackFunc[T]) Option {
+	return func(cfg *config) {
+		cfg.fallback = fn
+	}
+}
+
+// WithFailureRateThreshold enables the failure rate based tripping of the circuit breaker.
+// The outcomes of the last windowSize executions are collected and the circuit breaker trips
+// to its CircuitOpen state when the percentage of failures reaches the threshold.
+// When enabled, it replaces the consecutive failures threshold set by WithFailThreshold.
+func WithFailureRateThreshold(pct float64, windowSize int) Option {
+	return func(cfg *config) {
+		cfg.bucketWidth = 0
+		cfg.failureRateThreshold = pct
+		cfg.windowSize = windowSize
+	}
+}
+
+// WithRollingFailureRateThreshold enables the failure rate based tripping of the circuit breaker
+// over a time based rolling window.
+// The outcomes of the executions are collected in the given number of buckets, each one spanning
+// the bucket width, and the circuit breaker trips to its CircuitOpen state when the percentage
+// of failures in the window reaches the threshold.
+// When enabled, it replaces the consecutive failures threshold set by WithFailThreshold.
+func WithRollingFailureRateThreshold(pct float64, buckets int, bucketWidth time.Duration) Option {
+	return func(cfg *config) {
+		cfg.bucketWidth = bucketWidth
+		cfg.failureRateThreshold = pct
+		cfg.windowSize = buckets
+	}
+}
+
+// WithHalfOpenMaxCalls limits the number of concurrent trial executions allowed
+// while the circuit breaker is in its CircuitHalfOpen state.
+// Executions exceeding the limit fail immediately with ErrTooManyRequests.
+// By default, the number of concurrent trial executions is not limited.
+func WithHalfOpenMaxCalls(n int) Option {
+	return func(cfg *config) {
+		cfg.halfOpenMaxCalls = int32(n)
+	}
+}
+
+// WithIgnoreContextErrors sets whether errors caused by a cancelled or expired context
+// are excluded from the failure accounting of the circuit breaker.
+func WithIgnoreContextErrors(ignore bool) Option {
+	return func(cfg *config) {
+		cfg.ignoreContextErrors = ignore
+	}
+}
+
+// WithIgnoredErrors sets the errors excluded from the accounting of the circuit breaker.
+// An execution returning an error matching any of them, according to errors.Is,
+// is counted neither as a success nor as a failure.
+func WithIgnoredErrors(errs ...error) Option {
+	return func(cfg *config) {
+		cfg.ignoredErrors = append(cfg.ignoredErrors, errs...)
+	}
+}
+
+// WithLazyRestore sets whether the circuit breaker enters the CircuitHalfOpen state lazily.
+// When enabled, no background goroutines or timers are used by the circuit breaker: the time the circuit
+// is allowed to recover is recorded when it opens and the state is set to CircuitHalfOpen by
+// the first execution or State call after its expiration.
+// The state change functions are still notified asynchronously, each one through its own
+// queue drained by a goroutine that only runs while there are state changes to deliver.
+// No goroutine is started for the circuit breakers without state change functions.
+func WithLazyRestore(lazy bool) Option {
+	return func(cfg *config) {
+		cfg.lazyRestore = lazy
+	}
+}
+
+// WithMinimumRequests overrides the minimum number of executions the window must collect
+// before the failure rate is evaluated.
+// It defaults to the window size, that is the number of executions for count based windows
+// and the number of buckets for time based rolling windows.
+func WithMinimumRequests(n int) Option {
+	return func(cfg *config) {
+		cfg.minimumRequests = n
+	}
+}
+
+// WithSlowCallThreshold enables the slow call rate based tripping of the circuit breaker.
+// Executions taking longer than the duration are considered slow and the circuit breaker trips
+// to its CircuitOpen state when the percentage of slow calls in the window reaches the rate,
+// regardless of the failures.
+// Unless a window is configured by WithFailureRateThreshold or WithRollingFailureRateThreshold,
+// the outcomes of the last 10 executions are considered.
+func WithSlowCallThreshold(duration time.Duration, rate float64) Option {
+	return func(cfg *config) {
+		cfg.slowCallRateThreshold = rate
+		cfg.slowCallThreshold = duration
+	}
+}
+
+// WithName sets the name of the circuit breaker, reported by its events.
+// Named circuit breakers configured through the package level functions are named automatically.
+func WithName(name string) Option {
+	return func(cfg *config) {
+		cfg.name = name
+	}
+}
+
+// WithPanicPolicy overrides the default handling of the panics of the protected function, where the
+// panic is recovered and returned as a PanicError. The panic is recorded as a failure regardless of the policy.
+func WithPanicPolicy(policy PanicPolicy) Option {
+	return func(cfg *config) {
+		cfg.panicPolicy = policy
+	}
+}
+
+// WithStateChangeFunc sets the function that will receive notifications
+// of circuit breaker state changes, replacing the one set by a previous use of the option.
+// The function is notified asynchronously and in order through its own queue, so that a slow
+// or panicking function never blocks the circuit breaker nor the other functions.
 func WithStateChangeFunc(fn StateChangeFunc) Option {
 	return func(cfg *config) {
 		cfg.stateChangeFunc = fn
 	}
 }
 
+// WithAdditionalStateChangeFunc attaches a function that will receive notifications
+// of circuit breaker state changes, along with the one set by WithStateChangeFunc.
+// The option can be used more than once to attach multiple functions, each one notified
+// as described by WithStateChangeFunc.
+func WithAdditionalStateChangeFunc(fn StateChangeFunc) Option {
+	return func(cfg *config) {
+		cfg.stateChangeFuncs = append(cfg.stateChangeFuncs, fn)
+	}
+}
+
+// WithStateChangeQueueSize overrides the default size of the queue of pending notifications
+// for each function attached by WithStateChangeFunc or WithAdditionalStateChangeFunc.
+// Notifications are discarded while the queue is full.
+func WithStateChangeQueueSize(size int) Option {
+	return func(cfg *config) {
+		cfg.stateChangeQueueSize = size
+	}
+}
+
 // WithSuccessThreshold overrides the default value for the number of successful
 // executions required to restore the circuit breaker to its CircuitClosed state.
 func WithSuccessThreshold(threshold int) Option {
@@ -65,3 +241,25 @@ func WithWaitInterval(interval time.Duration) Option {
 		cfg.waitInterval = interval
 	}
 }
+
+// WithWaitIntervalBackoff enables the exponential growth of the time the circuit breaker
+// will wait before entering the CircuitHalfOpen state.
+// The wait interval starts from the initial value and it is multiplied by the multiplier
+// each time a recovery attempt fails, up to the maximum value.
+// It is restored to the initial value once the circuit breaker returns to its CircuitClosed state.
+func WithWaitIntervalBackoff(initial time.Duration, multiplier float64, maxInterval time.Duration) Option {
+	return func(cfg *config) {
+		cfg.maxWaitInterval = maxInterval
+		cfg.waitInterval = initial
+		cfg.waitMultiplier = multiplier
+	}
+}
+
+// WithWaitIntervalJitter randomizes the time the circuit breaker will wait before entering
+// the CircuitHalfOpen state by up to the given fraction of the wait interval, in both directions.
+// For example, a jitter of 0.1 applied to a 30 seconds interval results in a wait between 27 and 33 seconds.
+func WithWaitIntervalJitter(jitter float64) Option {
+	return func(cfg *config) {
+		cfg.waitJitter = jitter
+	}
+}
@@ -1,11 +1,15 @@
 package breaker
 
 import (
+	"context"
+	"io"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/mgiaccone/tripswitch/clock"
 )
 
 // func Test_config_apply(t *testing.T) {
@@ -43,6 +47,13 @@ import (
 // 	}
 // }
 
+func TestWithClock(t *testing.T) {
+	var cfg config
+	want := clock.NewFake(time.Now())
+	WithClock(want)(&cfg)
+	require.Equal(t, want, cfg.clock, "WithClock(): got = %v, want = %v", cfg.clock, want)
+}
+
 func TestWithFailThreshold(t *testing.T) {
 	var cfg config
 	want := 3
@@ -51,6 +62,85 @@ func TestWithFailThreshold(t *testing.T) {
 		"TestWithFailThreshold(): got = %v, want = %v", cfg.failThreshold, want)
 }
 
+func TestWithFailurePredicate(t *testing.T) {
+	var cfg config
+	want := func(res int, err error) bool { return err != nil }
+	WithFailurePredicate(want)(&cfg)
+	require.Equal(t, reflect.ValueOf(want).Pointer(), reflect.ValueOf(cfg.failurePredicate).Pointer(),
+		"WithFailurePredicate(): cfg = %v, want = %v", cfg.failurePredicate, want)
+}
+
+func TestWithFallback(t *testing.T) {
+	var cfg config
+	want := func(err error) (int, error) { return 0, nil }
+	WithFallback(want)(&cfg)
+	require.Equal(t, reflect.ValueOf(want).Pointer(), reflect.ValueOf(cfg.fallback).Pointer(),
+		"WithFallback(): cfg = %v, want = %v", cfg.fallback, want)
+}
+
+func TestWithFailureRateThreshold(t *testing.T) {
+	var cfg config
+	wantPct, wantSize := 50.0, 20
+	WithFailureRateThreshold(wantPct, wantSize)(&cfg)
+	require.Equal(t, wantPct, cfg.failureRateThreshold,
+		"WithFailureRateThreshold(): pct = %v, want = %v", cfg.failureRateThreshold, wantPct)
+	require.Equal(t, wantSize, cfg.windowSize,
+		"WithFailureRateThreshold(): windowSize = %v, want = %v", cfg.windowSize, wantSize)
+}
+
+func TestWithRollingFailureRateThreshold(t *testing.T) {
+	var cfg config
+	wantPct, wantBuckets, wantWidth := 50.0, 10, time.Second
+	WithRollingFailureRateThreshold(wantPct, wantBuckets, wantWidth)(&cfg)
+	require.Equal(t, wantPct, cfg.failureRateThreshold,
+		"WithRollingFailureRateThreshold(): pct = %v, want = %v", cfg.failureRateThreshold, wantPct)
+	require.Equal(t, wantBuckets, cfg.windowSize,
+		"WithRollingFailureRateThreshold(): buckets = %v, want = %v", cfg.windowSize, wantBuckets)
+	require.Equal(t, wantWidth, cfg.bucketWidth,
+		"WithRollingFailureRateThreshold(): bucketWidth = %v, want = %v", cfg.bucketWidth, wantWidth)
+}
+
+func TestWithIgnoredErrors(t *testing.T) {
+	var cfg config
+	want := []error{context.Canceled, io.EOF}
+	WithIgnoredErrors(want[0])(&cfg)
+	WithIgnoredErrors(want[1])(&cfg)
+	require.Equal(t, want, cfg.ignoredErrors,
+		"WithIgnoredErrors(): got = %v, want = %v", cfg.ignoredErrors, want)
+}
+
+func TestWithLazyRestore(t *testing.T) {
+	var cfg config
+	want := true
+	WithLazyRestore(want)(&cfg)
+	require.Equal(t, want, cfg.lazyRestore,
+		"WithLazyRestore(): got = %v, want = %v", cfg.lazyRestore, want)
+}
+
+func TestWithMinimumRequests(t *testing.T) {
+	var cfg config
+	want := 7
+	WithMinimumRequests(want)(&cfg)
+	require.Equal(t, want, cfg.minimumRequests,
+		"WithMinimumRequests(): got = %v, want = %v", cfg.minimumRequests, want)
+}
+
+func TestWithHalfOpenMaxCalls(t *testing.T) {
+	var cfg config
+	want := 5
+	WithHalfOpenMaxCalls(want)(&cfg)
+	require.Equal(t, int32(want), cfg.halfOpenMaxCalls,
+		"WithHalfOpenMaxCalls(): got = %v, want = %v", cfg.halfOpenMaxCalls, want)
+}
+
+func TestWithIgnoreContextErrors(t *testing.T) {
+	var cfg config
+	want := true
+	WithIgnoreContextErrors(want)(&cfg)
+	require.Equal(t, want, cfg.ignoreContextErrors,
+		"WithIgnoreContextErrors(): got = %v, want = %v", cfg.ignoreContextErrors, want)
+}
+
 func TestWithSuccessThreshold(t *testing.T) {
 	var cfg config
 	want := 3
@@ -67,10 +157,75 @@ func TestWithWaitInterval(t *testing.T) {
 		"WithWaitInterval(): got = %v, want = %v", cfg.waitInterval, want)
 }
 
+func TestWithSlowCallThreshold(t *testing.T) {
+	var cfg config
+	wantDuration, wantRate := time.Second, 25.0
+	WithSlowCallThreshold(wantDuration, wantRate)(&cfg)
+	require.Equal(t, wantDuration, cfg.slowCallThreshold,
+		"WithSlowCallThreshold(): duration = %v, want = %v", cfg.slowCallThreshold, wantDuration)
+	require.Equal(t, wantRate, cfg.slowCallRateThreshold,
+		"WithSlowCallThreshold(): rate = %v, want = %v", cfg.slowCallRateThreshold, wantRate)
+}
+
+func TestWithName(t *testing.T) {
+	var cfg config
+	want := "sample"
+	WithName(want)(&cfg)
+	require.Equal(t, want, cfg.name, "WithName(): got = %v, want = %v", cfg.name, want)
+}
+
+func TestWithPanicPolicy(t *testing.T) {
+	var cfg config
+	want := PanicRepanic
+	WithPanicPolicy(want)(&cfg)
+	require.Equal(t, want, cfg.panicPolicy, "WithPanicPolicy(): got = %v, want = %v", cfg.panicPolicy, want)
+}
+
 func TestWithStateChangeFunc(t *testing.T) {
 	var cfg config
+	prev := func(oldState, newState CircuitState) {}
 	want := func(oldState, newState CircuitState) {}
+	WithStateChangeFunc(prev)(&cfg)
 	WithStateChangeFunc(want)(&cfg)
 	require.Equal(t, reflect.ValueOf(want).Pointer(), reflect.ValueOf(cfg.stateChangeFunc).Pointer(),
 		"WithStateChangeFunc(): cfg = %v, want = %v", cfg.stateChangeFunc, want)
 }
+
+func TestWithAdditionalStateChangeFunc(t *testing.T) {
+	var cfg config
+	want := func(oldState, newState CircuitState) {}
+	WithAdditionalStateChangeFunc(want)(&cfg)
+	WithAdditionalStateChangeFunc(want)(&cfg)
+	require.Len(t, cfg.stateChangeFuncs, 2,
+		"WithAdditionalStateChangeFunc(): len = %v, want = %v", len(cfg.stateChangeFuncs), 2)
+	require.Equal(t, reflect.ValueOf(want).Pointer(), reflect.ValueOf(cfg.stateChangeFuncs[1]).Pointer(),
+		"WithAdditionalStateChangeFunc(): cfg = %v, want = %v", cfg.stateChangeFuncs[1], want)
+}
+
+func TestWithStateChangeQueueSize(t *testing.T) {
+	var cfg config
+	want := 32
+	WithStateChangeQueueSize(want)(&cfg)
+	require.Equal(t, want, cfg.stateChangeQueueSize,
+		"WithStateChangeQueueSize(): got = %v, want = %v", cfg.stateChangeQueueSize, want)
+}
+
+func TestWithWaitIntervalBackoff(t *testing.T) {
+	var cfg config
+	wantInitial, wantMultiplier, wantMax := time.Second, 2.0, time.Minute
+	WithWaitIntervalBackoff(wantInitial, wantMultiplier, wantMax)(&cfg)
+	require.Equal(t, wantInitial, cfg.waitInterval,
+		"WithWaitIntervalBackoff(): initial = %v, want = %v", cfg.waitInterval, wantInitial)
+	require.Equal(t, wantMultiplier, cfg.waitMultiplier,
+		"WithWaitIntervalBackoff(): multiplier = %v, want = %v", cfg.waitMultiplier, wantMultiplier)
+	require.Equal(t, wantMax, cfg.maxWaitInterval,
+		"WithWaitIntervalBackoff(): max = %v, want = %v", cfg.maxWaitInterval, wantMax)
+}
+
+func TestWithWaitIntervalJitter(t *testing.T) {
+	var cfg config
+	want := 0.2
+	WithWaitIntervalJitter(want)(&cfg)
+	require.Equal(t, want, cfg.waitJitter,
+		"WithWaitIntervalJitter(): got = %v, want = %v", cfg.waitJitter, want)
+}
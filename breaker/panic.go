@@ -0,0 +1,46 @@
+package breaker
+
+import (
+	"log"
+
+	"github.com/mgiaccone/tripswitch/internal/coreutil"
+)
+
+// PanicError is the error returned when a panic is recovered during the execution of a protected function.
+// It carries the recovered value and the stack trace, and matches ErrPanicRecovered when compared with errors.Is.
+type PanicError = coreutil.PanicError
+
+// PanicPolicy represents how the circuit breaker handles a panic of the protected function.
+// The panic is always recorded as a failure before the policy is applied.
+type PanicPolicy int
+
+// Enumeration of panic policies.
+const (
+	// PanicRecover recovers from the panic and returns a PanicError.
+	PanicRecover PanicPolicy = iota
+
+	// PanicReport recovers from the panic, reports it to the standard logger and returns a PanicError.
+	PanicReport
+
+	// PanicRepanic panics again with the recovered value.
+	PanicRepanic
+)
+
+// String returns a string representation of the panic policy.
+func (p PanicPolicy) String() string {
+	switch p {
+	case PanicRecover:
+		return "recover"
+	case PanicReport:
+		return "report"
+	case PanicRepanic:
+		return "repanic"
+	default:
+		return "unknown"
+	}
+}
+
+// reportPanic writes the recovered value and the stack trace of a panic to the standard logger.
+func reportPanic(name string, err *PanicError) {
+	log.Printf("tripswitch: circuit %q %v\n%s", name, err, err.Stack)
+}
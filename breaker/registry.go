@@ -0,0 +1,201 @@
+package breaker
+
+import (
+	"strings"
+	"sync"
+)
+
+// Registry is a set of named circuits, shared by all the result types.
+// The package level functions operate on a default registry.
+type Registry struct {
+	circuits    map[string]*entry
+	defaultOpts []Option
+	lock        sync.Mutex
+}
+
+// entry is a named circuit, along with the view created when it has been configured.
+type entry struct {
+	circuit *Circuit
+	view    any
+}
+
+// NewRegistry creates a new empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		circuits: make(map[string]*entry),
+	}
+}
+
+// Configure sets custom options for a named circuit.
+// A ConfigError listing all the invalid settings is returned if the options are not valid.
+func (r *Registry) Configure(name string, opts ...Option) error {
+	return r.add(name, opts, func(opts []Option) *entry {
+		return &entry{circuit: NewCircuit(opts...)}
+	})
+}
+
+// Circuit returns a named circuit, creating it with the default options of the registry if it does not exist.
+// The typed views of the circuit can be created with For.
+func (r *Registry) Circuit(name string) *Circuit {
+	return getOrCreate[any](r, name).Circuit
+}
+
+// ForceOpen sets the state of a named circuit to CircuitForcedOpen.
+func (r *Registry) ForceOpen(name string) error {
+	v, err := r.get(name)
+	if err != nil {
+		return err
+	}
+	v.circuit.ForceOpen()
+
+	return nil
+}
+
+// ForceClosed sets the state of a named circuit to CircuitForcedClosed.
+func (r *Registry) ForceClosed(name string) error {
+	v, err := r.get(name)
+	if err != nil {
+		return err
+	}
+	v.circuit.ForceClosed()
+
+	return nil
+}
+
+// Disable sets the state of a named circuit to CircuitDisabled.
+func (r *Registry) Disable(name string) error {
+	v, err := r.get(name)
+	if err != nil {
+		return err
+	}
+	v.circuit.Disable()
+
+	return nil
+}
+
+// Reset sets the state of a named circuit to CircuitClosed, clearing all its counters.
+func (r *Registry) Reset(name string) error {
+	v, err := r.get(name)
+	if err != nil {
+		return err
+	}
+	v.circuit.Reset()
+
+	return nil
+}
+
+// Reconfigure replaces the options of a named circuit while it is running, preserving its state and counters.
+// The options are applied on top of the default options of the registry, see Circuit.Reconfigure.
+func (r *Registry) Reconfigure(name string, opts ...Option) error {
+	v, err := r.get(name)
+	if err != nil {
+		return err
+	}
+
+	return v.circuit.Reconfigure(r.circuitOpts(name, opts)...)
+}
+
+// Stats returns a snapshot of the runtime statistics of all the named circuits.
+func (r *Registry) Stats() map[string]Stats {
+	r.lock.Lock()
+	circuits := make(map[string]*Circuit, len(r.circuits))
+	for name, v := range r.circuits {
+		circuits[name] = v.circuit
+	}
+	r.lock.Unlock()
+
+	stats := make(map[string]Stats, len(circuits))
+	for name, c := range circuits {
+		stats[name] = c.Stats()
+	}
+
+	return stats
+}
+
+// Remove closes a named circuit and removes it from the registry.
+func (r *Registry) Remove(name string) error {
+	r.lock.Lock()
+	v, exists := r.circuits[name]
+	delete(r.circuits, name)
+	r.lock.Unlock()
+
+	if !exists {
+		return ErrUnknownCircuit
+	}
+	v.circuit.Close()
+
+	return nil
+}
+
+// add registers a new named circuit created by newEntry with the default options of the registry,
+// followed by the given options, once they have been validated.
+func (r *Registry) add(name string, opts []Option, newEntry func(opts []Option) *entry) error {
+	if len(strings.TrimSpace(name)) == 0 {
+		return ErrRequiredName
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, exists := r.circuits[name]; exists {
+		return ErrDuplicateCircuit
+	}
+
+	cbOpts := r.circuitOpts(name, opts)
+	if err := validateOptions(cbOpts); err != nil {
+		return err
+	}
+
+	r.circuits[name] = newEntry(cbOpts)
+
+	return nil
+}
+
+// circuitOpts returns the options of a named circuit: the default options of the registry,
+// followed by the given options and its name.
+func (r *Registry) circuitOpts(name string, opts []Option) []Option {
+	return circuitOpts(r.defaultOpts, name, opts)
+}
+
+// circuitOpts returns the options of a named circuit: the given default options,
+// followed by the given options and its name.
+func circuitOpts(defaultOpts []Option, name string, opts []Option) []Option {
+	cbOpts := make([]Option, 0, len(defaultOpts)+len(opts)+1)
+	cbOpts = append(cbOpts, defaultOpts...)
+	cbOpts = append(cbOpts, opts...)
+
+	return append(cbOpts, WithName(name))
+}
+
+func (r *Registry) get(name string) (*entry, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	v, exists := r.circuits[name]
+	if !exists {
+		return nil, ErrUnknownCircuit
+	}
+
+	return v, nil
+}
+
+// getOrCreate returns a view of a named circuit for the result type T, creating the circuit if needed.
+// The view created when the circuit has been configured is returned for its own result type,
+// so that its retrier is applied.
+func getOrCreate[T any](r *Registry, name string) *CircuitBreaker[T] {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if v, exists := r.circuits[name]; exists {
+		if cb, ok := v.view.(*CircuitBreaker[T]); ok {
+			return cb
+		}
+
+		return For[T](v.circuit)
+	}
+
+	cb := NewCircuitBreaker[T](r.circuitOpts(name, nil)...)
+	r.circuits[name] = &entry{circuit: cb.Circuit, view: cb}
+
+	return cb
+}
@@ -0,0 +1,197 @@
+package breaker
+
+import (
+	"sync"
+	"time"
+)
+
+// Stats represents a snapshot of the runtime statistics of a circuit breaker.
+type Stats struct {
+	// State is the state of the circuit breaker when the snapshot was taken.
+	State CircuitState
+
+	// Requests is the total number of executions attempted, including the rejected ones.
+	Requests uint64
+
+	// Successes is the number of executions classified as successful.
+	Successes uint64
+
+	// Failures is the number of executions classified as failed.
+	Failures uint64
+
+	// Ignored is the number of executions excluded from the accounting.
+	Ignored uint64
+
+	// Rejections is the number of executions rejected by the circuit breaker.
+	Rejections uint64
+
+	// Panics is the number of executions recovered from a panic.
+	Panics uint64
+
+	// DroppedStateChanges is the number of state change notifications discarded
+	// because the queue of a listener was full.
+	DroppedStateChanges uint64
+
+	// ConsecutiveSuccesses is the number of successful executions since the last failure.
+	ConsecutiveSuccesses uint64
+
+	// ConsecutiveFailures is the number of failed executions since the last success.
+	ConsecutiveFailures uint64
+
+	// LastTransition is the time of the last state change,
+	// or the creation time of the circuit breaker if its state never changed.
+	LastTransition time.Time
+
+	// States contains the statistics for each state the circuit breaker has been in.
+	States map[CircuitState]StateStats
+
+	// Latency summarizes the duration of the completed executions.
+	Latency LatencyStats
+}
+
+// StateStats represents the statistics of a circuit breaker for a single state.
+type StateStats struct {
+	// Transitions is the number of times the circuit breaker entered the state.
+	Transitions uint64
+
+	// Requests is the number of executions attempted while in the state.
+	Requests uint64
+
+	// Duration is the total time spent in the state.
+	Duration time.Duration
+}
+
+// LatencyStats represents a summary of the duration of the executions.
+type LatencyStats struct {
+	// Count is the number of executions summarized.
+	Count uint64
+
+	// Min is the duration of the fastest execution.
+	Min time.Duration
+
+	// Max is the duration of the slowest execution.
+	Max time.Duration
+
+	// Mean is the average duration of the executions.
+	Mean time.Duration
+
+	// Total is the sum of the duration of the executions.
+	Total time.Duration
+}
+
+// record includes the duration of an execution in the summary.
+func (l *LatencyStats) record(elapsed time.Duration) {
+	if l.Count == 0 || elapsed < l.Min {
+		l.Min = elapsed
+	}
+
+	if elapsed > l.Max {
+		l.Max = elapsed
+	}
+
+	l.Count++
+	l.Total += elapsed
+	l.Mean = l.Total / time.Duration(l.Count)
+}
+
+// statsCollector collects the runtime statistics of a circuit breaker.
+type statsCollector struct {
+	lock  sync.Mutex
+	stats Stats
+}
+
+func newStatsCollector(now time.Time) *statsCollector {
+	return &statsCollector{
+		stats: Stats{
+			State:          CircuitClosed,
+			LastTransition: now,
+			States: map[CircuitState]StateStats{
+				CircuitClosed: {},
+			},
+		},
+	}
+}
+
+// recordRequest records an execution attempted in the given state.
+func (c *statsCollector) recordRequest(state CircuitState) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.stats.Requests++
+
+	s := c.stats.States[state]
+	s.Requests++
+	c.stats.States[state] = s
+}
+
+// recordRejection records an execution rejected by the circuit breaker.
+func (c *statsCollector) recordRejection() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.stats.Rejections++
+}
+
+// recordPanic records an execution recovered from a panic.
+func (c *statsCollector) recordPanic() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.stats.Panics++
+}
+
+// recordOutcome records the classified outcome of a completed execution.
+func (c *statsCollector) recordOutcome(class classification, elapsed time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	switch class {
+	case classifiedSuccess:
+		c.stats.Successes++
+		c.stats.ConsecutiveSuccesses++
+		c.stats.ConsecutiveFailures = 0
+	case classifiedFailure:
+		c.stats.Failures++
+		c.stats.ConsecutiveFailures++
+		c.stats.ConsecutiveSuccesses = 0
+	case classifiedIgnored:
+		c.stats.Ignored++
+	}
+
+	c.stats.Latency.record(elapsed)
+}
+
+// recordTransition records a state change happened at the given time.
+func (c *statsCollector) recordTransition(oldState, newState CircuitState, now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	old := c.stats.States[oldState]
+	old.Duration += now.Sub(c.stats.LastTransition)
+	c.stats.States[oldState] = old
+
+	s := c.stats.States[newState]
+	s.Transitions++
+	c.stats.States[newState] = s
+
+	c.stats.LastTransition = now
+	c.stats.State = newState
+}
+
+// snapshot returns a consistent copy of the statistics at the given time.
+func (c *statsCollector) snapshot(now time.Time) Stats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	stats := c.stats
+	stats.States = make(map[CircuitState]StateStats, len(c.stats.States))
+	for state, s := range c.stats.States {
+		stats.States[state] = s
+	}
+
+	current := stats.States[stats.State]
+	current.Duration += now.Sub(stats.LastTransition)
+	stats.States[stats.State] = current
+
+	return stats
+}
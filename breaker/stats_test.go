@@ -0,0 +1,72 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatencyStats_record(t *testing.T) {
+	var got LatencyStats
+	for _, d := range []time.Duration{20 * time.Millisecond, 10 * time.Millisecond, 30 * time.Millisecond} {
+		got.record(d)
+	}
+
+	want := LatencyStats{
+		Count: 3,
+		Min:   10 * time.Millisecond,
+		Max:   30 * time.Millisecond,
+		Mean:  20 * time.Millisecond,
+		Total: 60 * time.Millisecond,
+	}
+	require.Equal(t, want, got, "record() - got = %+v, want = %+v", got, want)
+}
+
+func TestStatsCollector_snapshot(t *testing.T) {
+	startTime := time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	c := newStatsCollector(startTime)
+	c.recordRequest(CircuitClosed)
+	c.recordOutcome(classifiedSuccess, 10*time.Millisecond)
+	c.recordRequest(CircuitClosed)
+	c.recordOutcome(classifiedFailure, 30*time.Millisecond)
+	c.recordRequest(CircuitClosed)
+	c.recordOutcome(classifiedFailure, 20*time.Millisecond)
+	c.recordTransition(CircuitClosed, CircuitOpen, startTime.Add(time.Second))
+	c.recordRequest(CircuitOpen)
+	c.recordRejection()
+	c.recordTransition(CircuitOpen, CircuitHalfOpen, startTime.Add(3*time.Second))
+	c.recordRequest(CircuitHalfOpen)
+	c.recordPanic()
+
+	want := Stats{
+		State:               CircuitHalfOpen,
+		Requests:            5,
+		Successes:           1,
+		Failures:            2,
+		Rejections:          1,
+		Panics:              1,
+		ConsecutiveFailures: 2,
+		LastTransition:      startTime.Add(3 * time.Second),
+		States: map[CircuitState]StateStats{
+			CircuitClosed:   {Transitions: 0, Requests: 3, Duration: time.Second},
+			CircuitOpen:     {Transitions: 1, Requests: 1, Duration: 2 * time.Second},
+			CircuitHalfOpen: {Transitions: 1, Requests: 1, Duration: 4 * time.Second},
+		},
+		Latency: LatencyStats{
+			Count: 3,
+			Min:   10 * time.Millisecond,
+			Max:   30 * time.Millisecond,
+			Mean:  20 * time.Millisecond,
+			Total: 60 * time.Millisecond,
+		},
+	}
+
+	got := c.snapshot(startTime.Add(7 * time.Second))
+	require.Equal(t, want, got, "snapshot() - got = %+v, want = %+v", got, want)
+
+	got.States[CircuitClosed] = StateStats{}
+	again := c.snapshot(startTime.Add(7 * time.Second))
+	require.Equal(t, want, again, "snapshot() - got = %+v, want = %+v", again, want)
+}
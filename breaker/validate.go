@@ -0,0 +1,132 @@
+package breaker
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldError describes an invalid setting of a circuit.
+type FieldError struct {
+	// Field is the name of the setting, such as "failThreshold".
+	Field string
+
+	// Reason describes why the value of the setting is not valid.
+	Reason string
+}
+
+// Error implements the error interface.
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Reason
+}
+
+// ConfigError is returned when the options of a circuit, or a declarative configuration, are not valid.
+// It matches ErrInvalidConfig.
+type ConfigError struct {
+	// Name is the name of the circuit, if any.
+	Name string
+
+	// Fields are all the invalid settings.
+	Fields []FieldError
+}
+
+// Error implements the error interface.
+func (e *ConfigError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, f.Error())
+	}
+
+	if e.Name == "" {
+		return fmt.Sprintf("%s: %s", ErrInvalidConfig, strings.Join(msgs, "; "))
+	}
+
+	return fmt.Sprintf("%s %q: %s", ErrInvalidConfig, e.Name, strings.Join(msgs, "; "))
+}
+
+// Is reports whether the target is ErrInvalidConfig.
+func (e *ConfigError) Is(target error) bool {
+	return target == ErrInvalidConfig
+}
+
+// validateOptions validates the options of a circuit, applied on top of the default options.
+func validateOptions(opts []Option) error {
+	_, err := validatedConfig(opts)
+	return err
+}
+
+// validatedConfig returns the configuration of a circuit with the options applied on top of the default options,
+// once it has been validated.
+func validatedConfig(opts []Option) (config, error) {
+	cfgOpts := _defaultOpts
+	cfgOpts = append(cfgOpts, opts...)
+
+	cfg := newConfig(cfgOpts...)
+	if err := cfg.validate(); err != nil {
+		return config{}, err
+	}
+
+	return cfg, nil
+}
+
+// validate returns a ConfigError listing all the invalid settings of the configuration, if any.
+func (c config) validate() error {
+	var fields []FieldError
+	addf := func(field, format string, args ...any) {
+		fields = append(fields, FieldError{Field: field, Reason: fmt.Sprintf(format, args...)})
+	}
+
+	if c.clock == nil {
+		addf("clock", "must not be nil")
+	}
+	if c.failThreshold <= 0 {
+		addf("failThreshold", "must be greater than 0, got %d", c.failThreshold)
+	}
+	if c.successThreshold <= 0 {
+		addf("successThreshold", "must be greater than 0, got %d", c.successThreshold)
+	}
+	if c.waitInterval <= 0 {
+		addf("waitInterval", "must be greater than 0, got %s", c.waitInterval)
+	}
+	if c.maxWaitInterval < 0 {
+		addf("maxWaitInterval", "must not be negative, got %s", c.maxWaitInterval)
+	}
+	if c.waitMultiplier != 0 && c.waitMultiplier < 1 {
+		addf("waitMultiplier", "must be at least 1, got %v", c.waitMultiplier)
+	}
+	if c.waitJitter < 0 || c.waitJitter > 1 {
+		addf("waitIntervalJitter", "must be between 0 and 1, got %v", c.waitJitter)
+	}
+	if c.halfOpenMaxCalls < 0 {
+		addf("halfOpenMaxCalls", "must not be negative, got %d", c.halfOpenMaxCalls)
+	}
+	if c.minimumRequests < 0 {
+		addf("minimumRequests", "must not be negative, got %d", c.minimumRequests)
+	}
+	if c.failureRateThreshold < 0 || c.failureRateThreshold > 100 {
+		addf("failureRateThreshold", "must be between 0 and 100, got %v", c.failureRateThreshold)
+	}
+	if c.slowCallRateThreshold < 0 || c.slowCallRateThreshold > 100 {
+		addf("slowCallRateThreshold", "must be between 0 and 100, got %v", c.slowCallRateThreshold)
+	}
+	if c.slowCallThreshold < 0 || (c.slowCallRateThreshold > 0 && c.slowCallThreshold == 0) {
+		addf("slowCallThreshold", "must be greater than 0, got %s", c.slowCallThreshold)
+	}
+	if (c.failureRateThreshold > 0 || c.slowCallRateThreshold > 0) && c.windowSize <= 0 {
+		addf("windowSize", "must be greater than 0, got %d", c.windowSize)
+	}
+	if c.bucketWidth < 0 {
+		addf("bucketWidth", "must not be negative, got %s", c.bucketWidth)
+	}
+	if c.stateChangeQueueSize <= 0 {
+		addf("stateChangeQueueSize", "must be greater than 0, got %d", c.stateChangeQueueSize)
+	}
+	if _, ok := parsePanicPolicy(c.panicPolicy.String()); !ok {
+		addf("panicPolicy", "unknown policy %d", c.panicPolicy)
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return &ConfigError{Name: c.name, Fields: fields}
+}
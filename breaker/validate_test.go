@@ -0,0 +1,157 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewValidatedCircuit(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []Option
+		wantFields []string
+	}{
+		{
+			name: "defaults",
+			opts: nil,
+		},
+		{
+			name: "valid options",
+			opts: []Option{
+				WithFailureRateThreshold(50, 20),
+				WithSlowCallThreshold(time.Second, 80),
+				WithWaitIntervalBackoff(time.Second, 2, time.Minute),
+				WithWaitIntervalJitter(0.1),
+			},
+		},
+		{
+			name:       "thresholds",
+			opts:       []Option{WithFailThreshold(0), WithSuccessThreshold(-1)},
+			wantFields: []string{"failThreshold", "successThreshold"},
+		},
+		{
+			name:       "wait interval",
+			opts:       []Option{WithWaitInterval(0)},
+			wantFields: []string{"waitInterval"},
+		},
+		{
+			name:       "wait interval backoff",
+			opts:       []Option{WithWaitIntervalBackoff(time.Second, 0.5, -time.Second), WithWaitIntervalJitter(2)},
+			wantFields: []string{"maxWaitInterval", "waitMultiplier", "waitIntervalJitter"},
+		},
+		{
+			name:       "failure rate",
+			opts:       []Option{WithFailureRateThreshold(150, 0), WithMinimumRequests(-1)},
+			wantFields: []string{"minimumRequests", "failureRateThreshold", "windowSize"},
+		},
+		{
+			name:       "slow call rate",
+			opts:       []Option{WithSlowCallThreshold(0, 50)},
+			wantFields: []string{"slowCallThreshold"},
+		},
+		{
+			name:       "miscellaneous",
+			opts:       []Option{WithHalfOpenMaxCalls(-1), WithStateChangeQueueSize(0), WithPanicPolicy(PanicPolicy(9))},
+			wantFields: []string{"halfOpenMaxCalls", "stateChangeQueueSize", "panicPolicy"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewValidatedCircuit(append(tt.opts, WithLazyRestore(true))...)
+			if len(tt.wantFields) == 0 {
+				require.NoError(t, err, "NewValidatedCircuit() - err = %v, want no error", err)
+				require.NotNil(t, got, "NewValidatedCircuit() - got = nil, want circuit")
+				return
+			}
+
+			require.ErrorIs(t, err, ErrInvalidConfig, "NewValidatedCircuit() - err = %v, wantErr = %v", err, ErrInvalidConfig)
+			require.Nil(t, got, "NewValidatedCircuit() - got = %v, want = nil", got)
+
+			var configErr *ConfigError
+			require.ErrorAs(t, err, &configErr, "NewValidatedCircuit() - err = %v, want ConfigError", err)
+
+			gotFields := make([]string, 0, len(configErr.Fields))
+			for _, f := range configErr.Fields {
+				gotFields = append(gotFields, f.Field)
+			}
+			require.Equal(t, tt.wantFields, gotFields, "NewValidatedCircuit() - fields = %v, want = %v", gotFields, tt.wantFields)
+		})
+	}
+}
+
+func TestNewValidatedCircuitBreakerWithRetrier(t *testing.T) {
+	tests := []struct {
+		name    string
+		retrier Retrier[int]
+		opts    []Option
+		wantErr error
+	}{
+		{
+			name:    "valid",
+			retrier: &nopRetrier[int]{},
+			opts:    []Option{WithFailThreshold(1)},
+		},
+		{
+			name:    "missing retrier",
+			retrier: nil,
+			wantErr: ErrRequiredRetrier,
+		},
+		{
+			name:    "invalid options",
+			retrier: &nopRetrier[int]{},
+			opts:    []Option{WithWaitInterval(0)},
+			wantErr: ErrInvalidConfig,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewValidatedCircuitBreakerWithRetrier(tt.retrier, append(tt.opts, WithLazyRestore(true))...)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr, "NewValidatedCircuitBreakerWithRetrier() - err = %v, wantErr = %v", err, tt.wantErr)
+				require.Nil(t, got, "NewValidatedCircuitBreakerWithRetrier() - got = %v, want = nil", got)
+				return
+			}
+
+			require.NoError(t, err, "NewValidatedCircuitBreakerWithRetrier() - err = %v, want no error", err)
+			require.Equal(t, tt.retrier, got.retrier,
+				"NewValidatedCircuitBreakerWithRetrier() - retrier = %v, want = %v", got.retrier, tt.retrier)
+		})
+	}
+}
+
+func TestConfigError_Error(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *ConfigError
+		want string
+	}{
+		{
+			name: "unnamed",
+			err:  &ConfigError{Fields: []FieldError{{Field: "failThreshold", Reason: "must be greater than 0, got 0"}}},
+			want: "invalid circuit configuration: failThreshold: must be greater than 0, got 0",
+		},
+		{
+			name: "named",
+			err: &ConfigError{Name: "users", Fields: []FieldError{
+				{Field: "failThreshold", Reason: "must be greater than 0, got 0"},
+				{Field: "waitInterval", Reason: "must be greater than 0, got 0s"},
+			}},
+			want: `invalid circuit configuration "users": failThreshold: must be greater than 0, got 0; ` +
+				"waitInterval: must be greater than 0, got 0s",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.err.Error()
+			require.Equal(t, tt.want, got, "Error() - got = %v, want = %v", got, tt.want)
+		})
+	}
+}
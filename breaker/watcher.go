@@ -0,0 +1,306 @@
+package breaker
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mgiaccone/tripswitch/clock"
+)
+
+const _defaultPollInterval = 5 * time.Second
+
+// ConfigDiff reports the named circuits affected by a reload of the configuration.
+type ConfigDiff struct {
+	// Added are the circuits registered by the reload.
+	Added []string
+
+	// Updated are the circuits reconfigured by the reload, either because their own
+	// settings or the defaults changed.
+	Updated []string
+
+	// Removed are the circuits closed and removed from the registry by the reload.
+	Removed []string
+}
+
+// Empty reports whether the reload did not affect any circuit.
+func (d ConfigDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Updated) == 0 && len(d.Removed) == 0
+}
+
+// WatchFunc is called each time a watcher reloads a changed configuration file.
+// When the configuration cannot be read, parsed or applied, the error is reported along with an empty diff
+// and the previous configuration is left in place.
+type WatchFunc func(diff ConfigDiff, err error)
+
+// WatchOption represents a functional option applicable to a configuration watcher.
+type WatchOption func(cfg *watchConfig)
+
+type watchConfig struct {
+	clock        clock.Clock
+	pollInterval time.Duration
+}
+
+// WithPollInterval sets the interval between two checks of the configuration file.
+// The default poll interval is 5 seconds, and it is kept when the interval is not positive.
+func WithPollInterval(interval time.Duration) WatchOption {
+	return func(cfg *watchConfig) {
+		if interval > 0 {
+			cfg.pollInterval = interval
+		}
+	}
+}
+
+// WithWatchClock overrides the source of time used by the watcher.
+func WithWatchClock(c clock.Clock) WatchOption {
+	return func(cfg *watchConfig) {
+		cfg.clock = c
+	}
+}
+
+// Watcher keeps the circuits of a registry in sync with a JSON configuration file, by polling it for changes.
+// The circuits added to the file are registered, the changed ones are reconfigured preserving their state,
+// and the ones removed from the file are closed and removed from the registry.
+// Only the circuits declared in the file are managed by the watcher.
+type Watcher struct {
+	clock     clock.Clock
+	closeOnce sync.Once
+	current   *Config
+	data      []byte
+	done      chan struct{}
+	fn        WatchFunc
+	interval  time.Duration
+	lock      sync.Mutex
+	path      string
+	readErr   bool
+	registry  *Registry
+	stopped   chan struct{}
+}
+
+// Watch loads a JSON configuration file of named circuits in the default registry and keeps watching it for changes.
+// See Registry.Watch for details.
+func Watch(path string, fn WatchFunc, opts ...WatchOption) (*Watcher, error) {
+	return _registry.Watch(path, fn, opts...)
+}
+
+// Watch loads a JSON configuration file of named circuits in the registry, as LoadConfigFile does,
+// and keeps watching it for changes until the returned watcher is closed.
+// The function fn, if not nil, is called with the outcome of each reload of the changed file.
+// A configuration that is not valid, or that declares a new circuit already registered by other means,
+// is rejected as a whole, leaving the previous configuration in place.
+// The lazy restore mode of the circuits already registered cannot be changed by a reload.
+func (r *Registry) Watch(path string, fn WatchFunc, opts ...WatchOption) (*Watcher, error) {
+	cfg := watchConfig{
+		clock:        clock.New(),
+		pollInterval: _defaultPollInterval,
+	}
+	for _, apply := range opts {
+		apply(&cfg)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := ParseConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := r.reload(nil, current); err != nil {
+		return nil, err
+	}
+
+	w := Watcher{
+		clock:    cfg.clock,
+		current:  current,
+		data:     data,
+		done:     make(chan struct{}),
+		fn:       fn,
+		interval: cfg.pollInterval,
+		path:     path,
+		registry: r,
+		stopped:  make(chan struct{}),
+	}
+
+	go w.run()
+
+	return &w, nil
+}
+
+// Reload checks the configuration file immediately, applying it if it changed.
+// Reloading a closed watcher has no effect.
+func (w *Watcher) Reload() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	select {
+	case <-w.done:
+		return
+	default:
+	}
+
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		// the read errors are reported once, until the file can be read again
+		if !w.readErr {
+			w.readErr = true
+			w.data = nil
+			w.report(ConfigDiff{}, err)
+		}
+
+		return
+	}
+	w.readErr = false
+
+	if bytes.Equal(data, w.data) {
+		return
+	}
+	w.data = data
+
+	next, err := ParseConfig(bytes.NewReader(data))
+	if err != nil {
+		w.report(ConfigDiff{}, err)
+		return
+	}
+
+	diff, err := w.registry.reload(w.current, next)
+	if err != nil {
+		w.report(ConfigDiff{}, err)
+		return
+	}
+	w.current = next
+
+	w.report(diff, nil)
+}
+
+// Close stops watching the configuration file. The circuits are left in the registry.
+// It waits for any reload in progress, so that the registry is no longer changed by the watcher
+// once it returns. For this reason, it must not be called by the WatchFunc.
+func (w *Watcher) Close() {
+	w.closeOnce.Do(func() {
+		close(w.done)
+	})
+
+	<-w.stopped
+
+	// a reload started by an explicit call to Reload is waited for as well
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.fn = nil
+}
+
+func (w *Watcher) run() {
+	defer close(w.stopped)
+
+	t := w.clock.NewTimer(w.interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-t.C():
+			w.Reload()
+			t.Reset(w.interval)
+		}
+	}
+}
+
+func (w *Watcher) report(diff ConfigDiff, err error) {
+	if w.fn != nil {
+		w.fn(diff, err)
+	}
+}
+
+// reload applies the changes between two configurations to the registry.
+// No change is applied if any of the added circuits already exists, or if the options of any of the added
+// and updated circuits, merged with the defaults, are not valid.
+func (r *Registry) reload(prev, next *Config) (ConfigDiff, error) {
+	diff := diffConfig(prev, next)
+
+	defaultOpts := next.Defaults.Options()
+	configs, err := circuitConfigs(defaultOpts, next, append(diff.Added[:len(diff.Added):len(diff.Added)], diff.Updated...))
+	if err != nil {
+		return ConfigDiff{}, err
+	}
+
+	r.lock.Lock()
+
+	for _, name := range diff.Added {
+		if _, exists := r.circuits[name]; exists {
+			r.lock.Unlock()
+			return ConfigDiff{}, fmt.Errorf("%w: %s", ErrDuplicateCircuit, name)
+		}
+	}
+
+	r.defaultOpts = defaultOpts
+	for _, name := range diff.Added {
+		r.circuits[name] = &entry{circuit: newCircuit(configs[name])}
+	}
+
+	for _, name := range diff.Updated {
+		if v, exists := r.circuits[name]; exists {
+			v.circuit.reconfigure(configs[name])
+			continue
+		}
+
+		// the circuit has been removed from the registry since the previous reload
+		r.circuits[name] = &entry{circuit: newCircuit(configs[name])}
+	}
+
+	removed := make([]*Circuit, 0, len(diff.Removed))
+	for _, name := range diff.Removed {
+		if v, exists := r.circuits[name]; exists {
+			removed = append(removed, v.circuit)
+			delete(r.circuits, name)
+		}
+	}
+
+	r.lock.Unlock()
+
+	for _, c := range removed {
+		c.Close()
+	}
+
+	return diff, nil
+}
+
+// diffConfig returns the circuits added, updated and removed by the next configuration, sorted by name.
+// All the circuits are updated when the defaults change.
+func diffConfig(prev, next *Config) ConfigDiff {
+	if prev == nil {
+		prev = &Config{}
+	}
+
+	var diff ConfigDiff
+	defaultsChanged := !reflect.DeepEqual(prev.Defaults, next.Defaults)
+
+	for name, c := range next.Circuits {
+		prevCircuit, exists := prev.Circuits[name]
+		switch {
+		case !exists:
+			diff.Added = append(diff.Added, name)
+		case defaultsChanged || !reflect.DeepEqual(prevCircuit, c):
+			diff.Updated = append(diff.Updated, name)
+		}
+	}
+
+	for name := range prev.Circuits {
+		if _, exists := next.Circuits[name]; !exists {
+			diff.Removed = append(diff.Removed, name)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Updated)
+	sort.Strings(diff.Removed)
+
+	return diff
+}
@@ -0,0 +1,268 @@
+package breaker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/mgiaccone/tripswitch/clock"
+)
+
+func TestDiffConfig(t *testing.T) {
+	one, two := 1, 2
+
+	tests := []struct {
+		name string
+		prev *Config
+		next *Config
+		want ConfigDiff
+	}{
+		{
+			name: "initial",
+			prev: nil,
+			next: &Config{Circuits: map[string]CircuitConfig{"b": {}, "a": {}}},
+			want: ConfigDiff{Added: []string{"a", "b"}},
+		},
+		{
+			name: "unchanged",
+			prev: &Config{Circuits: map[string]CircuitConfig{"a": {FailThreshold: &one}}},
+			next: &Config{Circuits: map[string]CircuitConfig{"a": {FailThreshold: &one}}},
+			want: ConfigDiff{},
+		},
+		{
+			name: "added, updated and removed",
+			prev: &Config{Circuits: map[string]CircuitConfig{"a": {FailThreshold: &one}, "b": {}, "c": {}}},
+			next: &Config{Circuits: map[string]CircuitConfig{"a": {FailThreshold: &two}, "b": {}, "d": {}}},
+			want: ConfigDiff{Added: []string{"d"}, Updated: []string{"a"}, Removed: []string{"c"}},
+		},
+		{
+			name: "defaults changed",
+			prev: &Config{Circuits: map[string]CircuitConfig{"a": {}, "b": {}}},
+			next: &Config{Defaults: CircuitConfig{FailThreshold: &one}, Circuits: map[string]CircuitConfig{"a": {}, "b": {}}},
+			want: ConfigDiff{Updated: []string{"a", "b"}},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffConfig(tt.prev, tt.next)
+			require.Equal(t, tt.want, got, "diffConfig() - got = %v, want = %v", got, tt.want)
+		})
+	}
+}
+
+func TestRegistry_Watch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "circuits.json")
+	writeFile := func(data string) {
+		err := os.WriteFile(path, []byte(data), 0o600)
+		require.NoError(t, err, "WriteFile() - err = %v, want no error", err)
+	}
+
+	writeFile(`{"circuits": {"users": {"failThreshold": 1}, "orders": {}}}`)
+
+	fakeClock := clock.NewFake(time.Unix(1000, 0))
+	reloadCh := make(chan struct{}, 1)
+
+	var (
+		gotDiff ConfigDiff
+		gotErr  error
+	)
+
+	r := NewRegistry()
+	w, err := r.Watch(path, func(diff ConfigDiff, err error) {
+		gotDiff, gotErr = diff, err
+		reloadCh <- struct{}{}
+	}, WithPollInterval(time.Second), WithWatchClock(fakeClock))
+	require.NoError(t, err, "Watch() - err = %v, want no error", err)
+	defer w.Close()
+
+	require.Len(t, r.Stats(), 2, "Watch() - circuits = %v, want 2", len(r.Stats()))
+
+	users := r.Circuit("users")
+	require.NoError(t, r.ForceOpen("users"), "ForceOpen() - want no error")
+
+	poll := func() {
+		fakeClock.BlockUntil(1)
+		fakeClock.Advance(time.Second)
+
+		select {
+		case <-reloadCh:
+		case <-time.After(time.Second):
+			require.Fail(t, "Watch() - reload not reported")
+		}
+	}
+
+	// the reloads of invalid configurations keep the previous one
+	writeFile(`{"circuits": {"users": {"failThreshold": 0}}}`)
+	poll()
+	require.ErrorIs(t, gotErr, ErrInvalidConfig, "Watch() - err = %v, wantErr = %v", gotErr, ErrInvalidConfig)
+	require.True(t, gotDiff.Empty(), "Watch() - diff = %v, want empty", gotDiff)
+	require.Len(t, r.Stats(), 2, "Watch() - circuits = %v, want 2", len(r.Stats()))
+
+	_ = r.Circuit("lazy")
+	writeFile(`{"circuits": {"lazy": {}}}`)
+	poll()
+	require.ErrorIs(t, gotErr, ErrDuplicateCircuit, "Watch() - err = %v, wantErr = %v", gotErr, ErrDuplicateCircuit)
+	require.Contains(t, r.Stats(), "orders", "Watch() - orders removed after error")
+
+	writeFile(`{
+		"defaults": {"waitInterval": "1h"},
+		"circuits": {"users": {"failThreshold": 2}, "payments": {}}
+	}`)
+	poll()
+	require.NoError(t, gotErr, "Watch() - err = %v, want no error", gotErr)
+
+	wantDiff := ConfigDiff{Added: []string{"payments"}, Updated: []string{"users"}, Removed: []string{"orders"}}
+	require.Equal(t, wantDiff, gotDiff, "Watch() - diff = %v, want = %v", gotDiff, wantDiff)
+	require.NotContains(t, r.Stats(), "orders", "Watch() - orders not removed")
+	require.Contains(t, r.Stats(), "payments", "Watch() - payments not added")
+
+	// the updated circuits keep their state
+	require.Same(t, users, r.Circuit("users"), "Watch() - users circuit replaced")
+	require.Equal(t, CircuitForcedOpen, users.State(), "Watch() - state = %v, want = %v", users.State(), CircuitForcedOpen)
+	require.Equal(t, int32(2), users.settings().failThreshold,
+		"Watch() - failThreshold = %v, want = %v", users.settings().failThreshold, 2)
+	require.Equal(t, time.Hour, users.settings().waitInterval,
+		"Watch() - waitInterval = %v, want = %v", users.settings().waitInterval, time.Hour)
+
+	// the read errors are reported once
+	require.NoError(t, os.Remove(path), "Remove() - want no error")
+	poll()
+	require.ErrorIs(t, gotErr, os.ErrNotExist, "Watch() - err = %v, wantErr = %v", gotErr, os.ErrNotExist)
+
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(time.Second)
+	fakeClock.BlockUntil(1)
+	require.Len(t, reloadCh, 0, "Watch() - read error reported twice")
+}
+
+func TestWatcher_Close(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "circuits.json")
+	writeFile := func(data string) {
+		err := os.WriteFile(path, []byte(data), 0o600)
+		require.NoError(t, err, "WriteFile() - err = %v, want no error", err)
+	}
+
+	writeFile(`{"circuits": {"users": {}}}`)
+
+	fakeClock := clock.NewFake(time.Unix(1000, 0))
+	reloadCh := make(chan struct{})
+	releaseCh := make(chan struct{})
+
+	r := NewRegistry()
+	w, err := r.Watch(path, func(diff ConfigDiff, err error) {
+		close(reloadCh)
+		<-releaseCh
+	}, WithPollInterval(time.Second), WithWatchClock(fakeClock))
+	require.NoError(t, err, "Watch() - err = %v, want no error", err)
+
+	writeFile(`{"circuits": {"users": {}, "orders": {}}}`)
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(time.Second)
+	<-reloadCh
+
+	closedCh := make(chan struct{})
+	go func() {
+		w.Close()
+		close(closedCh)
+	}()
+
+	select {
+	case <-closedCh:
+		require.Fail(t, "Close() - returned while a reload is in progress")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(releaseCh)
+
+	select {
+	case <-closedCh:
+	case <-time.After(time.Second):
+		require.Fail(t, "Close() - not returned after the reload completed")
+	}
+
+	// the registry is no longer changed once the watcher is closed
+	writeFile(`{"circuits": {"payments": {}}}`)
+	w.Reload()
+	require.Contains(t, r.Stats(), "users", "Reload() - users removed after Close")
+	require.NotContains(t, r.Stats(), "payments", "Reload() - payments added after Close")
+}
+
+func TestWithPollInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		want     time.Duration
+	}{
+		{name: "positive", interval: time.Second, want: time.Second},
+		{name: "zero", interval: 0, want: _defaultPollInterval},
+		{name: "negative", interval: -time.Second, want: _defaultPollInterval},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := watchConfig{pollInterval: _defaultPollInterval}
+			WithPollInterval(tt.interval)(&cfg)
+			require.Equal(t, tt.want, cfg.pollInterval, "WithPollInterval() - got = %v, want = %v", cfg.pollInterval, tt.want)
+		})
+	}
+}
+
+func TestRegistry_Watch_invalid(t *testing.T) {
+	r := NewRegistry()
+
+	_, err := r.Watch(filepath.Join(t.TempDir(), "missing.json"), nil)
+	require.ErrorIs(t, err, os.ErrNotExist, "Watch() - err = %v, wantErr = %v", err, os.ErrNotExist)
+
+	path := filepath.Join(t.TempDir(), "circuits.json")
+	err = os.WriteFile(path, []byte(`{"circuits": {"users": {"successThreshold": -1}}}`), 0o600)
+	require.NoError(t, err, "WriteFile() - err = %v, want no error", err)
+
+	_, err = r.Watch(path, nil)
+	require.ErrorIs(t, err, ErrInvalidConfig, "Watch() - err = %v, wantErr = %v", err, ErrInvalidConfig)
+	require.True(t, strings.Contains(err.Error(), "successThreshold"), "Watch() - err = %v, want successThreshold", err)
+	require.Empty(t, r.Stats(), "Watch() - circuits registered after error")
+}
+
+func TestRegistry_reload_invalidMergedOptions(t *testing.T) {
+	r := NewRegistry()
+
+	prev, err := ParseConfig(strings.NewReader(`{"circuits": {"users": {"failThreshold": 1}}}`))
+	require.NoError(t, err, "ParseConfig() - err = %v, want no error", err)
+
+	_, err = r.reload(nil, prev)
+	require.NoError(t, err, "reload() - err = %v, want no error", err)
+
+	next, err := ParseConfig(strings.NewReader(`{
+		"defaults": {"waitInterval": "1h"},
+		"circuits": {"users": {"failThreshold": 2}, "orders": {"successThreshold": 2}}
+	}`))
+	require.NoError(t, err, "ParseConfig() - err = %v, want no error", err)
+
+	// the options are only invalid once merged with the default options
+	t.Cleanup(func() { DefaultOptions() })
+	DefaultOptions(WithSuccessThreshold(0))
+
+	diff, err := r.reload(prev, next)
+	require.ErrorIs(t, err, ErrInvalidConfig, "reload() - err = %v, wantErr = %v", err, ErrInvalidConfig)
+	require.True(t, diff.Empty(), "reload() - diff = %v, want empty", diff)
+
+	var configErr *ConfigError
+	require.ErrorAs(t, err, &configErr, "reload() - err = %v, want ConfigError", err)
+	wantFields := []FieldError{{Field: "circuits.users.successThreshold", Reason: "must be greater than 0, got 0"}}
+	require.Equal(t, wantFields, configErr.Fields, "reload() - fields = %v, want = %v", configErr.Fields, wantFields)
+
+	// the previous configuration is left in place
+	require.NotContains(t, r.Stats(), "orders", "reload() - orders added after error")
+	require.Empty(t, r.defaultOpts, "reload() - defaults replaced after error")
+
+	users := r.Circuit("users")
+	require.Equal(t, int32(1), users.settings().failThreshold,
+		"reload() - failThreshold = %v, want = %v", users.settings().failThreshold, 1)
+}
@@ -0,0 +1,225 @@
+package breaker
+
+import (
+	"sync"
+	"time"
+)
+
+const _percent = 100
+
+// outcome represents the outcome of a protected function execution.
+type outcome struct {
+	failure bool
+	slow    bool
+}
+
+// windowCounts represents the aggregated outcomes collected by a window.
+type windowCounts struct {
+	failures int
+	slow     int
+	total    int
+}
+
+// failureRate returns the percentage of failed executions.
+func (c windowCounts) failureRate() float64 {
+	if c.total == 0 {
+		return 0
+	}
+
+	return float64(c.failures) * _percent / float64(c.total)
+}
+
+// slowCallRate returns the percentage of slow executions.
+func (c windowCounts) slowCallRate() float64 {
+	if c.total == 0 {
+		return 0
+	}
+
+	return float64(c.slow) * _percent / float64(c.total)
+}
+
+// merge includes the counts of another window in the counts.
+func (c *windowCounts) merge(other windowCounts) {
+	c.failures += other.failures
+	c.slow += other.slow
+	c.total += other.total
+}
+
+// add includes an outcome in the counts.
+func (c *windowCounts) add(o outcome) {
+	c.total++
+	if o.failure {
+		c.failures++
+	}
+	if o.slow {
+		c.slow++
+	}
+}
+
+// remove excludes an outcome from the counts.
+func (c *windowCounts) remove(o outcome) {
+	c.total--
+	if o.failure {
+		c.failures--
+	}
+	if o.slow {
+		c.slow--
+	}
+}
+
+// window is the interface representing a sliding window of execution outcomes.
+type window interface {
+	record(o outcome) windowCounts
+	reset()
+}
+
+// newWindow creates the window matching the configuration.
+// It returns nil if neither failure rate nor slow call rate based tripping has been configured.
+func newWindow(cfg config) window {
+	if (cfg.failureRateThreshold <= 0 && cfg.slowCallRateThreshold <= 0) || cfg.windowSize <= 0 {
+		return nil
+	}
+
+	if cfg.bucketWidth > 0 {
+		return newTimeWindow(cfg.windowSize, cfg.bucketWidth, cfg.clock.Now)
+	}
+
+	return newCountWindow(cfg.windowSize)
+}
+
+// minimumRequests returns the number of executions a window must collect
+// before its failure rate is evaluated.
+// It defaults to the window size, that is the number of executions of a count based window
+// or the number of buckets of a time based window.
+func minimumRequests(cfg config) int {
+	switch {
+	case cfg.bucketWidth > 0 && cfg.minimumRequests > 0:
+		return cfg.minimumRequests
+	case cfg.minimumRequests > 0 && cfg.minimumRequests < cfg.windowSize:
+		return cfg.minimumRequests
+	}
+
+	return cfg.windowSize
+}
+
+// countWindow is a sliding window keeping the outcomes of the last N executions.
+type countWindow struct {
+	counts   windowCounts
+	lock     sync.Mutex
+	next     int
+	outcomes []outcome
+}
+
+func newCountWindow(size int) *countWindow {
+	return &countWindow{
+		outcomes: make([]outcome, size),
+	}
+}
+
+// record adds an outcome to the window, evicting the oldest one when the window is full.
+func (w *countWindow) record(o outcome) windowCounts {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.counts.total == len(w.outcomes) {
+		w.counts.remove(w.outcomes[w.next])
+	}
+
+	w.outcomes[w.next] = o
+	w.counts.add(o)
+	w.next = (w.next + 1) % len(w.outcomes)
+
+	return w.counts
+}
+
+// reset clears all the outcomes in the window.
+func (w *countWindow) reset() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.counts = windowCounts{}
+	w.next = 0
+}
+
+// bucket represents the outcomes collected during a time interval.
+type bucket struct {
+	counts windowCounts
+	epoch  int64
+}
+
+// timeWindow is a rolling window keeping the outcomes of the executions
+// in the last N time intervals, each one tracked by a separate bucket.
+// The epochs of the buckets are relative to the creation of the window.
+type timeWindow struct {
+	buckets []bucket
+	lock    sync.Mutex
+	nowFn   func() time.Time
+	start   time.Time
+	width   time.Duration
+}
+
+func newTimeWindow(size int, width time.Duration, nowFn func() time.Time) *timeWindow {
+	return &timeWindow{
+		buckets: make([]bucket, size),
+		nowFn:   nowFn,
+		start:   nowFn(),
+		width:   width,
+	}
+}
+
+// record adds an outcome to the bucket of the current time interval
+// and returns the counts of all the buckets still in the window.
+func (w *timeWindow) record(o outcome) windowCounts {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	size := int64(len(w.buckets))
+	epoch := floorDiv(int64(w.nowFn().Sub(w.start)), int64(w.width))
+
+	b := &w.buckets[floorMod(epoch, size)]
+	if b.epoch != epoch {
+		b.counts = windowCounts{}
+		b.epoch = epoch
+	}
+	b.counts.add(o)
+
+	var counts windowCounts
+	for i := range w.buckets {
+		// the buckets ahead of the current one are left by a clock moving backwards
+		if age := epoch - w.buckets[i].epoch; age >= 0 && age < size {
+			counts.merge(w.buckets[i].counts)
+		}
+	}
+
+	return counts
+}
+
+// reset clears all the buckets in the window.
+func (w *timeWindow) reset() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for i := range w.buckets {
+		w.buckets[i] = bucket{}
+	}
+}
+
+// floorDiv returns the quotient of a and b rounded towards negative infinity.
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if (a%b != 0) && ((a < 0) != (b < 0)) {
+		q--
+	}
+
+	return q
+}
+
+// floorMod returns the non-negative remainder of a divided by the positive b.
+func floorMod(a, b int64) int64 {
+	m := a % b
+	if m < 0 {
+		m += b
+	}
+
+	return m
+}
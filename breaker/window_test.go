@@ -0,0 +1,303 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountWindow_record(t *testing.T) {
+	success := outcome{}
+	failure := outcome{failure: true}
+
+	tests := []struct {
+		name     string
+		size     int
+		outcomes []outcome
+		want     windowCounts
+	}{
+		{
+			name:     "empty window",
+			size:     3,
+			outcomes: nil,
+			want:     windowCounts{},
+		},
+		{
+			name:     "partially filled window",
+			size:     3,
+			outcomes: []outcome{failure, success},
+			want:     windowCounts{failures: 1, total: 2},
+		},
+		{
+			name:     "full window",
+			size:     3,
+			outcomes: []outcome{failure, success, failure},
+			want:     windowCounts{failures: 2, total: 3},
+		},
+		{
+			name:     "oldest outcomes are evicted",
+			size:     3,
+			outcomes: []outcome{failure, failure, success, success, failure},
+			want:     windowCounts{failures: 1, total: 3},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			w := newCountWindow(tt.size)
+
+			var got windowCounts
+			for _, o := range tt.outcomes {
+				got = w.record(o)
+			}
+
+			require.Equal(t, tt.want, got, "record() - got = %+v, want = %+v", got, tt.want)
+		})
+	}
+}
+
+func TestCountWindow_reset(t *testing.T) {
+	w := newCountWindow(2)
+	w.record(outcome{failure: true})
+	w.record(outcome{failure: true})
+	w.reset()
+
+	want := windowCounts{failures: 0, total: 1}
+	got := w.record(outcome{})
+	require.Equal(t, want, got, "reset() - got = %+v, want = %+v", got, want)
+}
+
+func TestWindowCounts_slowCallRate(t *testing.T) {
+	tests := []struct {
+		name   string
+		counts windowCounts
+		want   float64
+	}{
+		{
+			name:   "no executions",
+			counts: windowCounts{},
+			want:   0,
+		},
+		{
+			name:   "some slow calls",
+			counts: windowCounts{slow: 3, total: 4},
+			want:   75,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.counts.slowCallRate()
+			require.Equal(t, tt.want, got, "slowCallRate() - got = %v, want = %v", got, tt.want)
+		})
+	}
+}
+
+func TestWindowCounts_failureRate(t *testing.T) {
+	tests := []struct {
+		name   string
+		counts windowCounts
+		want   float64
+	}{
+		{
+			name:   "no executions",
+			counts: windowCounts{},
+			want:   0,
+		},
+		{
+			name:   "some failures",
+			counts: windowCounts{failures: 1, total: 4},
+			want:   25,
+		},
+		{
+			name:   "only failures",
+			counts: windowCounts{failures: 5, total: 5},
+			want:   100,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.counts.failureRate()
+			require.Equal(t, tt.want, got, "failureRate() - got = %v, want = %v", got, tt.want)
+		})
+	}
+}
+
+func TestTimeWindow_record(t *testing.T) {
+	type step struct {
+		offset  time.Duration
+		outcome outcome
+	}
+
+	success := outcome{}
+	failure := outcome{failure: true}
+
+	tests := []struct {
+		name  string
+		steps []step
+		want  windowCounts
+	}{
+		{
+			name:  "single bucket",
+			steps: []step{{0, failure}, {100 * time.Millisecond, success}},
+			want:  windowCounts{failures: 1, total: 2},
+		},
+		{
+			name:  "multiple buckets",
+			steps: []step{{0, failure}, {time.Second, failure}, {2 * time.Second, success}},
+			want:  windowCounts{failures: 2, total: 3},
+		},
+		{
+			name:  "expired buckets are excluded",
+			steps: []step{{0, failure}, {time.Second, failure}, {3 * time.Second, success}},
+			want:  windowCounts{failures: 1, total: 2},
+		},
+		{
+			name:  "reused buckets are cleared",
+			steps: []step{{0, failure}, {3 * time.Second, success}},
+			want:  windowCounts{failures: 0, total: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			startTime := time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)
+			now := startTime
+
+			w := newTimeWindow(3, time.Second, func() time.Time { return now })
+
+			var got windowCounts
+			for _, s := range tt.steps {
+				now = startTime.Add(s.offset)
+				got = w.record(s.outcome)
+			}
+
+			require.Equal(t, tt.want, got, "record() - got = %+v, want = %+v", got, tt.want)
+		})
+	}
+}
+
+func TestTimeWindow_record_clock(t *testing.T) {
+	failure := outcome{failure: true}
+
+	tests := []struct {
+		name      string
+		startTime time.Time
+		offsets   []time.Duration
+		want      windowCounts
+	}{
+		{
+			name:      "zero time",
+			startTime: time.Time{},
+			offsets:   []time.Duration{0, time.Second, 2 * time.Second},
+			want:      windowCounts{failures: 3, total: 3},
+		},
+		{
+			name:      "before the unix epoch",
+			startTime: time.Date(1960, 1, 1, 0, 0, 0, 0, time.UTC),
+			offsets:   []time.Duration{0, 1500 * time.Millisecond},
+			want:      windowCounts{failures: 2, total: 2},
+		},
+		{
+			name:      "clock moving backwards",
+			startTime: time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC),
+			offsets:   []time.Duration{2 * time.Second, -1500 * time.Millisecond},
+			want:      windowCounts{failures: 1, total: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			now := tt.startTime
+			w := newTimeWindow(3, time.Second, func() time.Time { return now })
+
+			var got windowCounts
+			for _, offset := range tt.offsets {
+				now = tt.startTime.Add(offset)
+				got = w.record(failure)
+			}
+
+			require.Equal(t, tt.want, got, "record() - got = %+v, want = %+v", got, tt.want)
+		})
+	}
+}
+
+func Test_floorDiv(t *testing.T) {
+	tests := []struct {
+		a, b    int64
+		wantDiv int64
+		wantMod int64
+	}{
+		{a: 7, b: 3, wantDiv: 2, wantMod: 1},
+		{a: -7, b: 3, wantDiv: -3, wantMod: 2},
+		{a: -6, b: 3, wantDiv: -2, wantMod: 0},
+		{a: 0, b: 3, wantDiv: 0, wantMod: 0},
+	}
+
+	for _, tt := range tests {
+		gotDiv, gotMod := floorDiv(tt.a, tt.b), floorMod(tt.a, tt.b)
+		require.Equal(t, tt.wantDiv, gotDiv, "floorDiv(%d, %d) - got = %v, want = %v", tt.a, tt.b, gotDiv, tt.wantDiv)
+		require.Equal(t, tt.wantMod, gotMod, "floorMod(%d, %d) - got = %v, want = %v", tt.a, tt.b, gotMod, tt.wantMod)
+	}
+}
+
+func TestTimeWindow_reset(t *testing.T) {
+	now := time.Now()
+	w := newTimeWindow(3, time.Second, func() time.Time { return now })
+	w.record(outcome{failure: true})
+	w.record(outcome{failure: true})
+	w.reset()
+
+	want := windowCounts{failures: 0, total: 1}
+	got := w.record(outcome{})
+	require.Equal(t, want, got, "reset() - got = %+v, want = %+v", got, want)
+}
+
+func Test_minimumRequests(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config
+		want int
+	}{
+		{
+			name: "count window defaults to the window size",
+			cfg:  config{windowSize: 10},
+			want: 10,
+		},
+		{
+			name: "count window with minimum requests",
+			cfg:  config{windowSize: 10, minimumRequests: 5},
+			want: 5,
+		},
+		{
+			name: "count window with minimum requests above the window size",
+			cfg:  config{windowSize: 10, minimumRequests: 20},
+			want: 10,
+		},
+		{
+			name: "time window defaults to the number of buckets",
+			cfg:  config{windowSize: 10, bucketWidth: time.Second},
+			want: 10,
+		},
+		{
+			name: "time window with minimum requests",
+			cfg:  config{windowSize: 10, bucketWidth: time.Second, minimumRequests: 20},
+			want: 20,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got := minimumRequests(tt.cfg)
+			require.Equal(t, tt.want, got, "minimumRequests() - got = %v, want = %v", got, tt.want)
+		})
+	}
+}
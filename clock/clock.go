@@ -0,0 +1,75 @@
+package clock
+
+import (
+	"time"
+)
+
+// Clock is the interface representing a source of time.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// NewTimer creates a new Timer that will send the current time on its channel after at least duration d.
+	NewTimer(d time.Duration) Timer
+
+	// AfterFunc waits for the duration to elapse and then calls fn.
+	// The returned Timer can be used to cancel the call using its Stop method.
+	AfterFunc(d time.Duration, fn func()) Timer
+}
+
+// Timer is the interface representing a single event timer.
+type Timer interface {
+	// C returns the channel on which the time is delivered.
+	C() <-chan time.Time
+
+	// Stop prevents the Timer from firing.
+	// It returns true if the call stops the timer, false if the timer has already expired or been stopped.
+	Stop() bool
+
+	// Reset changes the timer to expire after duration d.
+	// It returns true if the timer had been active, false if the timer had expired or been stopped.
+	Reset(d time.Duration) bool
+}
+
+// New creates a new instance of a clock backed by the system time.
+func New() Clock {
+	return &realClock{}
+}
+
+// realClock is the implementation of a clock backed by the time package.
+type realClock struct{}
+
+// Now implements the Clock interface.
+func (c *realClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTimer implements the Clock interface.
+func (c *realClock) NewTimer(d time.Duration) Timer {
+	return &realTimer{timer: time.NewTimer(d)}
+}
+
+// AfterFunc implements the Clock interface.
+func (c *realClock) AfterFunc(d time.Duration, fn func()) Timer {
+	return &realTimer{timer: time.AfterFunc(d, fn)}
+}
+
+// realTimer is the implementation of a timer backed by the time package.
+type realTimer struct {
+	timer *time.Timer
+}
+
+// C implements the Timer interface.
+func (t *realTimer) C() <-chan time.Time {
+	return t.timer.C
+}
+
+// Stop implements the Timer interface.
+func (t *realTimer) Stop() bool {
+	return t.timer.Stop()
+}
+
+// Reset implements the Timer interface.
+func (t *realTimer) Reset(d time.Duration) bool {
+	return t.timer.Reset(d)
+}
@@ -0,0 +1,33 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRealClock_NewTimer(t *testing.T) {
+	c := New()
+
+	startTime := c.Now()
+	timer := c.NewTimer(10 * time.Millisecond)
+	<-timer.C()
+	elapsed := time.Since(startTime)
+
+	require.GreaterOrEqual(t, elapsed, 10*time.Millisecond, "NewTimer() - elapsed = %v, want >= %v", elapsed, 10*time.Millisecond)
+	require.False(t, timer.Stop(), "Stop() - got = true, want = false")
+}
+
+func TestRealClock_AfterFunc(t *testing.T) {
+	c := New()
+
+	firedCh := make(chan struct{})
+	c.AfterFunc(10*time.Millisecond, func() { close(firedCh) })
+
+	select {
+	case <-firedCh:
+	case <-time.After(time.Second):
+		require.Fail(t, "AfterFunc() - function not called")
+	}
+}
@@ -0,0 +1,172 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Fake is an implementation of a clock that only moves when it is advanced manually.
+// It allows to drive time based behaviours deterministically in tests.
+type Fake struct {
+	cond   *sync.Cond
+	lock   sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFake creates a new instance of a fake clock set to the given time.
+func NewFake(now time.Time) *Fake {
+	c := &Fake{now: now}
+	c.cond = sync.NewCond(&c.lock)
+
+	return c
+}
+
+// Now implements the Clock interface.
+func (c *Fake) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.now
+}
+
+// NewTimer implements the Clock interface.
+func (c *Fake) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{
+		c:     make(chan time.Time, 1),
+		clock: c,
+	}
+	t.Reset(d)
+
+	return t
+}
+
+// AfterFunc implements the Clock interface.
+// The function is called synchronously by the goroutine advancing the clock.
+func (c *Fake) AfterFunc(d time.Duration, fn func()) Timer {
+	t := &fakeTimer{
+		clock: c,
+		fn:    fn,
+	}
+	t.Reset(d)
+
+	return t
+}
+
+// Advance moves the clock forward by the given duration, firing all the timers expiring in the meantime.
+func (c *Fake) Advance(d time.Duration) {
+	c.lock.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+
+	var expired []*fakeTimer
+
+	active := c.timers[:0]
+	for _, t := range c.timers {
+		if t.when.After(now) {
+			active = append(active, t)
+			continue
+		}
+		expired = append(expired, t)
+	}
+	c.timers = active
+	c.lock.Unlock()
+
+	sort.Slice(expired, func(i, j int) bool {
+		return expired[i].when.Before(expired[j].when)
+	})
+
+	for _, t := range expired {
+		t.fire(now)
+	}
+}
+
+// BlockUntil blocks until the given number of timers are waiting to fire.
+func (c *Fake) BlockUntil(n int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+// Waiters returns the number of timers waiting to fire.
+func (c *Fake) Waiters() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return len(c.timers)
+}
+
+// schedule adds a timer to the list of the active timers.
+// It reports whether the timer was already active.
+func (c *Fake) schedule(t *fakeTimer, d time.Duration) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	active := c.unschedule(t)
+	t.when = c.now.Add(d)
+	c.timers = append(c.timers, t)
+	c.cond.Broadcast()
+
+	return active
+}
+
+// stop removes a timer from the list of the active timers.
+// It reports whether the timer was active.
+func (c *Fake) stop(t *fakeTimer) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.unschedule(t)
+}
+
+// unschedule removes a timer from the list of the active timers, assuming the lock is held.
+func (c *Fake) unschedule(t *fakeTimer) bool {
+	for i, v := range c.timers {
+		if v == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// fakeTimer is the implementation of a timer driven by a fake clock.
+type fakeTimer struct {
+	c     chan time.Time
+	clock *Fake
+	fn    func()
+	when  time.Time
+}
+
+// C implements the Timer interface.
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+// Stop implements the Timer interface.
+func (t *fakeTimer) Stop() bool {
+	return t.clock.stop(t)
+}
+
+// Reset implements the Timer interface.
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	return t.clock.schedule(t, d)
+}
+
+// fire delivers the expiration of the timer.
+func (t *fakeTimer) fire(now time.Time) {
+	if t.fn != nil {
+		t.fn()
+		return
+	}
+
+	select {
+	case t.c <- now:
+	default:
+	}
+}
@@ -0,0 +1,87 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFake_Advance(t *testing.T) {
+	startTime := time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFake(startTime)
+
+	timer := c.NewTimer(time.Second)
+
+	var fired []string
+	c.AfterFunc(2*time.Second, func() { fired = append(fired, "second") })
+	c.AfterFunc(time.Second, func() { fired = append(fired, "first") })
+
+	c.Advance(500 * time.Millisecond)
+	require.Equal(t, startTime.Add(500*time.Millisecond), c.Now(), "Now() - got = %v, want = %v", c.Now(), startTime.Add(500*time.Millisecond))
+	require.Equal(t, 3, c.Waiters(), "Waiters() - got = %v, want = %v", c.Waiters(), 3)
+	require.Empty(t, fired, "Advance() - fired = %v, want none", fired)
+
+	select {
+	case <-timer.C():
+		require.Fail(t, "Advance() - timer fired too early")
+	default:
+	}
+
+	c.Advance(2 * time.Second)
+	require.Equal(t, 0, c.Waiters(), "Waiters() - got = %v, want = %v", c.Waiters(), 0)
+	require.Equal(t, []string{"first", "second"}, fired, "Advance() - fired = %v, want = %v", fired, []string{"first", "second"})
+
+	select {
+	case got := <-timer.C():
+		require.Equal(t, c.Now(), got, "C() - got = %v, want = %v", got, c.Now())
+	default:
+		require.Fail(t, "Advance() - timer not fired")
+	}
+}
+
+func TestFake_Stop(t *testing.T) {
+	c := NewFake(time.Now())
+
+	fired := false
+	timer := c.AfterFunc(time.Second, func() { fired = true })
+
+	require.True(t, timer.Stop(), "Stop() - got = false, want = true")
+	require.False(t, timer.Stop(), "Stop() - got = true, want = false")
+
+	c.Advance(time.Second)
+	require.False(t, fired, "Advance() - stopped timer fired")
+}
+
+func TestFake_Reset(t *testing.T) {
+	c := NewFake(time.Now())
+
+	timer := c.NewTimer(time.Second)
+	require.True(t, timer.Reset(2*time.Second), "Reset() - got = false, want = true")
+
+	c.Advance(time.Second)
+	require.Equal(t, 1, c.Waiters(), "Waiters() - got = %v, want = %v", c.Waiters(), 1)
+
+	c.Advance(time.Second)
+	require.Equal(t, 0, c.Waiters(), "Waiters() - got = %v, want = %v", c.Waiters(), 0)
+	require.False(t, timer.Reset(time.Second), "Reset() - got = true, want = false")
+}
+
+func TestFake_BlockUntil(t *testing.T) {
+	c := NewFake(time.Now())
+
+	doneCh := make(chan struct{})
+	go func() {
+		c.BlockUntil(2)
+		close(doneCh)
+	}()
+
+	c.NewTimer(time.Second)
+	c.NewTimer(time.Second)
+
+	select {
+	case <-doneCh:
+	case <-time.After(time.Second):
+		require.Fail(t, "BlockUntil() - not unblocked")
+	}
+}
@@ -2,10 +2,11 @@ module github.com/mgiaccone/tripswitch
 
 go 1.18
 
+require github.com/stretchr/testify v1.8.0
+
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/stretchr/objx v0.4.0 // indirect
-	github.com/stretchr/testify v1.8.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
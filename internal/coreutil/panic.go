@@ -3,6 +3,7 @@ package coreutil
 import (
 	"errors"
 	"fmt"
+	"runtime/debug"
 )
 
 var (
@@ -10,6 +11,43 @@ var (
 	ErrPanicRecovered = errors.New("panic recovered")
 )
 
+// PanicError is the error representing a recovered panic.
+// It matches ErrPanicRecovered when compared with errors.Is.
+type PanicError struct {
+	// Value is the value passed to panic.
+	Value any
+
+	// Stack is the stack trace of the goroutine at the time of the panic.
+	Stack []byte
+}
+
+// NewPanicError creates a new PanicError for the recovered value, capturing the current stack trace.
+func NewPanicError(v any) *PanicError {
+	return &PanicError{
+		Value: v,
+		Stack: debug.Stack(),
+	}
+}
+
+// Error implements the error interface.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("%s: %v", ErrPanicRecovered, e.Value)
+}
+
+// Is reports whether the target is ErrPanicRecovered.
+func (e *PanicError) Is(target error) bool {
+	return target == ErrPanicRecovered
+}
+
+// Unwrap returns the recovered value when it is an error.
+func (e *PanicError) Unwrap() error {
+	if err, ok := e.Value.(error); ok {
+		return err
+	}
+
+	return nil
+}
+
 // MustErr trigger a panic if it detects an error.
 func MustErr(err error) {
 	if err != nil {
@@ -25,9 +63,9 @@ func MustResErr[T any](_ T, err error) {
 }
 
 // RecoverPanic is an utility function to handler the recovery from a panic.
-func RecoverPanic() {
-	if r := recover(); r != nil {
-		// TODO: implement recovered panic output
-		fmt.Println("Recovering from panic:", r)
+// It must be deferred directly and, when err is not nil, it stores a PanicError into it.
+func RecoverPanic(err *error) {
+	if r := recover(); r != nil && err != nil {
+		*err = NewPanicError(r)
 	}
 }
@@ -1,6 +1,7 @@
 package retrier
 
 import (
+	"context"
 	"errors"
 	"time"
 
@@ -25,14 +26,31 @@ func NewConstantRetrier[T any](delay time.Duration, maxRetries int) *ConstantRet
 
 // Do implement the ProtectedFunc interface.
 func (r *ConstantRetrier[T]) Do(fn ProtectedFunc[T]) (res T, err error) {
-	err = ErrPanicRecovered
-	defer coreutil.RecoverPanic()
+	defer coreutil.RecoverPanic(&err)
 
 	// TODO: missing implementation
 
 	res, err = fn()
 	if errors.Is(err, breaker.ErrCircuitOpen) {
-		return res, breaker.ErrCircuitOpen
+		return res, err
+	}
+
+	return
+}
+
+// DoContext implement the ProtectedContextFunc interface.
+func (r *ConstantRetrier[T]) DoContext(ctx context.Context, fn ProtectedContextFunc[T]) (res T, err error) {
+	defer coreutil.RecoverPanic(&err)
+
+	if err = ctx.Err(); err != nil {
+		return res, err
+	}
+
+	// TODO: missing implementation
+
+	res, err = fn(ctx)
+	if errors.Is(err, breaker.ErrCircuitOpen) {
+		return res, err
 	}
 
 	return
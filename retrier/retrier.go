@@ -1,6 +1,7 @@
 package retrier
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/mgiaccone/tripswitch/internal/coreutil"
@@ -11,9 +12,15 @@ var (
 	ErrPanicRecovered = coreutil.ErrPanicRecovered
 )
 
+// PanicError is the error returned when a panic is recovered during the execution of a function.
+type PanicError = coreutil.PanicError
+
 // ProtectedFunc represents the function to be protected by the circuit breaker.
 type ProtectedFunc[T any] func() (T, error)
 
+// ProtectedContextFunc represents the context aware function to be protected by the circuit breaker.
+type ProtectedContextFunc[T any] func(ctx context.Context) (T, error)
+
 // Retriable is a short-hand function to wrap an error into a RetriableError.
 func Retriable(err error) error {
 	return &RetriableError{Err: err}